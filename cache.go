@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/* CACHE MODES */
+
+// The size runs read right after inserting, so their reads mostly come from
+// shared_buffers and the WiredTiger cache. The cache sweep runs every read
+// cold, after evicting the caches, and warm, after running it once before.
+
+// cacheModes are the modes of the cache sweep in the order they run.
+var cacheModes = []string{"Cold", "Warm"}
+
+// SweepCache fills every variant with projects and runs every read operation
+// in each cache mode. Variants that cannot evict their cache, such as SQLite
+// and remote targets, show "-" when cold, as do variants whose eviction fails,
+// which is logged. It returns the table rows and the reads on which variants
+// returned different numbers of records.
+func SweepCache(variants []Variant, size int) (rows [][]string, mismatches []CardinalityMismatch, err error) {
+	projects := GenerateProjects(size)
+	err = FillVariants(variants, projects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	evictFailed := make(map[string]bool)
+	for _, mode := range cacheModes {
+		for _, operation := range operations {
+			if !operation.Read {
+				continue
+			}
+
+			row := []string{mode, operation.Name}
+			results := make(map[string]Measurement)
+			for _, variant := range variants {
+				run, ok := variant.Operations[operation.Name]
+				cold := mode == "Cold"
+				if !ok || cold && (variant.Evict == nil || evictFailed[variant.Name]) {
+					row = append(row, "-")
+					continue
+				}
+
+				if cold {
+					err = variant.Evict()
+					if err != nil {
+						log.Printf("cannot evict the caches of %s: %v", variant.Name, err)
+						evictFailed[variant.Name] = true
+						row = append(row, "-")
+						continue
+					}
+				} else {
+					_, err = run(projects)
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+
+				result, err := run(projects)
+				if err != nil {
+					return nil, nil, err
+				}
+				results[variant.Name] = result
+				row = append(row, result.Duration.String())
+			}
+
+			name := fmt.Sprintf("%s %s", mode, operation.Name)
+			for _, mismatch := range VerifyCardinality(size, name, variants, results) {
+				log.Printf("%s returned %d records for %s, %s returned %d", mismatch.Variant, mismatch.Actual, mismatch.Operation, mismatch.Reference, mismatch.Expected)
+				mismatches = append(mismatches, mismatch)
+				for i, variant := range variants {
+					if variant.Name == mismatch.Variant {
+						row[2+i] += " (rows differ)"
+					}
+				}
+			}
+
+			rows = append(rows, row)
+		}
+		rows = append(rows, []string{})
+	}
+
+	return rows, mismatches, nil
+}
+
+/* MONGODB */
+
+// evictedCache is the size the WiredTiger cache is shrunk to for eviction,
+// the smallest it takes.
+const evictedCache = 1 << 20
+
+// EvictMongo empties the WiredTiger cache by shrinking it to the smallest
+// size it takes and growing it back once eviction has caught up, which
+// evicts what it holds without restarting the server. Atlas does not allow
+// changing the cache size.
+func EvictMongo(database *mongo.Database) (err error) {
+	ctx := context.Background()
+	admin := database.Client().Database("admin")
+
+	maximum, _, err := mongoCache(ctx, admin)
+	if err != nil {
+		return err
+	}
+	if maximum == 0 {
+		return errors.New("server does not report a WiredTiger cache size")
+	}
+
+	err = setMongoCache(ctx, admin, evictedCache)
+	if err != nil {
+		return err
+	}
+
+	// Eviction runs in the background, so wait until the cache stops
+	// shrinking. Internal pages keep it from ever getting quite empty.
+	previous := int64(-1)
+	deadline := time.Now().Add(connectTimeout)
+	for time.Now().Before(deadline) {
+		_, current, err := mongoCache(ctx, admin)
+		if err != nil {
+			return err
+		}
+		if current <= 2*evictedCache || current == previous {
+			break
+		}
+		previous = current
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return setMongoCache(ctx, admin, maximum)
+}
+
+// mongoCache returns the configured size of the WiredTiger cache and how much
+// of it is in use.
+func mongoCache(ctx context.Context, admin *mongo.Database) (maximum int64, current int64, err error) {
+	var status struct {
+		WiredTiger struct {
+			Cache struct {
+				Maximum float64 `bson:"maximum bytes configured"`
+				Current float64 `bson:"bytes currently in the cache"`
+			} `bson:"cache"`
+		} `bson:"wiredTiger"`
+	}
+	err = admin.RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).Decode(&status)
+	return int64(status.WiredTiger.Cache.Maximum), int64(status.WiredTiger.Cache.Current), err
+}
+
+func setMongoCache(ctx context.Context, admin *mongo.Database, size int64) error {
+	return admin.RunCommand(ctx, bson.D{
+		{Key: "setParameter", Value: 1},
+		{Key: "wiredTigerEngineRuntimeConfig", Value: fmt.Sprintf("cache_size=%d", size)},
+	}).Err()
+}
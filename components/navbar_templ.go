@@ -29,7 +29,7 @@ func Navbar() templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<nav class=\"navbar is-primary\" role=\"navigation\" aria-label=\"main navigation\"><div class=\"navbar-brand\"><a class=\"navbar-item\" href=\"/\"><i class=\"las la-vial la-2x\"></i><h1 class=\"is-size-4 has-text-weight-semibold\">DBI Performance Tests</h1></a></div><div class=\"navbar-menu\"><div class=\"navbar-start\"><a class=\"navbar-item\" href=\"/postgres\">Postgres</a> <a class=\"navbar-item\" href=\"/mongo\">Mongo</a></div></div></nav>")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<nav class=\"navbar is-primary\" role=\"navigation\" aria-label=\"main navigation\"><div class=\"navbar-brand\"><a class=\"navbar-item\" href=\"/\"><i class=\"las la-vial la-2x\"></i><h1 class=\"is-size-4 has-text-weight-semibold\">DBI Performance Tests</h1></a></div><div class=\"navbar-menu\"><div class=\"navbar-start\"><a class=\"navbar-item\" href=\"/postgres\">Postgres</a> <a class=\"navbar-item\" href=\"/mongo\">Mongo</a> <a class=\"navbar-item\" href=\"/sqlite\">SQLite</a></div></div></nav>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
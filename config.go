@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Config is the targets file given with -targets. DSNs may reference secrets
+// as ${NAME}, which are looked up in the credentials file first and then in
+// the environment, so that the file itself never has to contain passwords.
+type Config struct {
+	CredentialsFile string   `json:"credentials_file"`
+	Postgres        []Target `json:"postgres"`
+	Mongo           []Target `json:"mongo"`
+}
+
+func LoadConfig(path string) (config Config, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+
+	credentials := make(map[string]string)
+	if config.CredentialsFile != "" {
+		credentials, err = LoadCredentials(config.CredentialsFile)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	for _, targets := range [][]Target{config.Postgres, config.Mongo} {
+		for i := range targets {
+			target := &targets[i]
+			target.DSN, err = ExpandSecrets(target.DSN, credentials)
+			if err != nil {
+				// A secret that is not there only disables an optional
+				// target, which is then dropped like an unreachable one.
+				if !target.Optional {
+					return config, fmt.Errorf("target %s: %w", target.Name, err)
+				}
+				target.Missing = err
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// LoadCredentials reads a file of NAME=value lines. Empty lines and lines
+// starting with # are skipped.
+func LoadCredentials(path string) (credentials map[string]string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	credentials = make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s: expected NAME=value", path)
+		}
+		credentials[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return credentials, scanner.Err()
+}
+
+// ExpandSecrets replaces ${NAME} references with their value and registers
+// every value with the redactor.
+func ExpandSecrets(text string, credentials map[string]string) (expanded string, err error) {
+	expanded = os.Expand(text, func(name string) string {
+		value, ok := credentials[name]
+		if !ok {
+			value, ok = os.LookupEnv(name)
+		}
+		if !ok && err == nil {
+			err = fmt.Errorf("secret %s is not set", name)
+		}
+
+		redactor.Add(value)
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return expanded, nil
+}
+
+/* REDACTION */
+
+// redactor hides credentials in everything the benchmark logs or writes.
+var redactor = &Redactor{}
+
+type Redactor struct {
+	mu      sync.Mutex
+	secrets []string
+}
+
+func (r *Redactor) Add(secret string) {
+	if secret == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = append(r.secrets, secret, url.QueryEscape(secret))
+}
+
+// AddDSN registers the password of a connection string, whether or not it
+// came from a secret reference.
+func (r *Redactor) AddDSN(dsn string) {
+	u, err := url.Parse(dsn)
+	if err == nil && u.User != nil {
+		password, _ := u.User.Password()
+		r.Add(password)
+		return
+	}
+
+	// key=value connection strings as understood by libpq
+	for _, field := range strings.Fields(dsn) {
+		if password, ok := strings.CutPrefix(field, "password="); ok {
+			r.Add(strings.Trim(password, "'"))
+		}
+	}
+}
+
+func (r *Redactor) Redact(text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, "[REDACTED]")
+	}
+	return text
+}
+
+// Writer redacts each write before passing it on. Secrets split across two
+// writes are not caught, so callers should write whole messages at once.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return redactingWriter{r, w}
+}
+
+type redactingWriter struct {
+	redactor *Redactor
+	w        io.Writer
+}
+
+func (rw redactingWriter) Write(p []byte) (n int, err error) {
+	_, err = io.WriteString(rw.w, rw.redactor.Redact(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteRedacted buffers everything render writes and stores it redacted in
+// the file at path.
+func WriteRedacted(path string, render func(w io.Writer) error) (err error) {
+	var buf bytes.Buffer
+	err = render(&buf)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(redactor.Redact(buf.String())), 0o644)
+}
+
+// IntList is a flag of comma separated numbers, for example "10,100,1000".
+type IntList []int
+
+func (list *IntList) String() string {
+	if list == nil {
+		return ""
+	}
+	values := make([]string, 0, len(*list))
+	for _, value := range *list {
+		values = append(values, strconv.Itoa(value))
+	}
+	return strings.Join(values, ",")
+}
+
+func (list *IntList) Set(value string) error {
+	values := make(IntList, 0)
+	for _, field := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || number <= 0 {
+			return fmt.Errorf("expected positive numbers separated by commas, not %q", value)
+		}
+		values = append(values, number)
+	}
+	*list = values
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// clientCPU returns the CPU time the benchmark's process has spent so far,
+// in user and system mode. That includes the sampler polling the server in
+// the background, which takes a small share of it.
+func clientCPU() time.Duration {
+	var usage syscall.Rusage
+	if syscall.Getrusage(syscall.RUSAGE_SELF, &usage) != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
@@ -1,11 +1,17 @@
 package db
 
 import (
-	"github.com/jackc/pgx/v5"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
-	PostgresConn *pgx.Conn
+	PostgresConn *pgxpool.Pool
 	MongoConn    *mongo.Client
-)
\ No newline at end of file
+	SqliteConn   *sql.DB
+
+	// MongoDatabase is the database on MongoConn the web UI works with.
+	MongoDatabase = "test"
+)
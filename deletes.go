@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/* DELETES */
+
+// Deleting projects removes their sprints, and owners that are left without
+// a project go with them, since the embedded model cannot keep a user
+// without a project. Deleting a user removes all of their projects.
+
+// deleteDurations is the range of sprint durations "Delete by filter"
+// removes, about a tenth of the projects.
+var deleteDurations = [2]int{41, 50}
+
+/* POSTGRES */
+
+// DeletePostgresProjects deletes the projects matching the condition in a
+// transaction. With cascade the sprints are removed by their foreign key,
+// otherwise they are deleted explicitly first. The condition uses $1, $2 and
+// so on for the arguments.
+func DeletePostgresProjects(conn *pgxpool.Pool, cascade bool, condition string, args ...any) (duration time.Duration, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return time.Since(now), err
+	}
+	defer tx.Rollback(ctx)
+
+	if !cascade {
+		_, err = tx.Exec(ctx, `DELETE FROM sprints WHERE project_id IN (SELECT id FROM projects WHERE `+condition+`);`, args...)
+		if err != nil {
+			return time.Since(now), err
+		}
+	}
+
+	rows, err := tx.Query(ctx, `DELETE FROM projects WHERE `+condition+` RETURNING owner_id;`, args...)
+	if err != nil {
+		return time.Since(now), err
+	}
+	owners, err := pgx.CollectRows(rows, pgx.RowTo[int32])
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM users WHERE id = ANY($1) AND NOT EXISTS (SELECT 1 FROM projects WHERE owner_id = users.id);`, owners)
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	err = tx.Commit(ctx)
+	return time.Since(now), err
+}
+
+// DeletePostgresUser deletes the users with the username, whose projects and
+// their sprints follow through the foreign keys.
+func DeletePostgresUser(conn *pgxpool.Pool, username string) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = conn.Exec(context.Background(), `DELETE FROM users WHERE username = $1;`, username)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+/* SQLITE */
+
+// DeleteSqliteProjects is DeletePostgresProjects for SQLite. The condition
+// uses ?1, ?2 and so on for the arguments.
+func DeleteSqliteProjects(conn *sql.DB, cascade bool, condition string, args ...any) (duration time.Duration, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Since(now), err
+	}
+	defer tx.Rollback()
+
+	if !cascade {
+		_, err = tx.ExecContext(ctx, `DELETE FROM sprints WHERE project_id IN (SELECT id FROM projects WHERE `+condition+`);`, args...)
+		if err != nil {
+			return time.Since(now), err
+		}
+	}
+
+	// SQLite has no arrays to pass the owners back in, so the owners are
+	// collected in a temporary table instead.
+	_, err = tx.ExecContext(ctx, `CREATE TEMP TABLE IF NOT EXISTS deleted_owners (id INTEGER PRIMARY KEY); DELETE FROM deleted_owners;`)
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO deleted_owners SELECT owner_id FROM projects WHERE `+condition+`;`, args...)
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM projects WHERE `+condition+`;`, args...)
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id IN (SELECT id FROM deleted_owners) AND NOT EXISTS (SELECT 1 FROM projects WHERE owner_id = users.id);`)
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	err = tx.Commit()
+	return time.Since(now), err
+}
+
+func DeleteSqliteUser(conn *sql.DB, username string) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = conn.ExecContext(context.Background(), `DELETE FROM users WHERE username = ?;`, username)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+/* MONGODB */
+
+// IndexMongoReferences indexes the references of the referencing model, which
+// deletes follow from a project to its sprints and from a user to their
+// projects, like the indexes on project_id and owner_id do in SQL.
+func IndexMongoReferences(database *mongo.Database) (err error) {
+	_, err = database.Collection("sprints").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "project", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = database.Collection("projects").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "owner", Value: 1}},
+	})
+	return err
+}
+
+// DeleteMongoWhere deletes the embedded projects matching the filter, which
+// removes their sprints and owners along with them.
+func DeleteMongoWhere(database *mongo.Database, collection string, filter bson.M) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = database.Collection(collection).DeleteMany(context.Background(), filter)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+// DeleteMongoProjectsWithReferencing deletes the referencing projects
+// matching the filter and cleans up the sprints and owners that pointed at
+// them, since nothing cascades between collections.
+func DeleteMongoProjectsWithReferencing(database *mongo.Database, filter bson.M) (duration time.Duration, err error) {
+	now := time.Now()
+	err = deleteMongoProjectsWithReferencing(context.Background(), database, filter)
+	return time.Since(now), err
+}
+
+// DeleteMongoUserWithReferencing deletes the users with the username
+// together with their projects and the sprints of those.
+func DeleteMongoUserWithReferencing(database *mongo.Database, username string) (duration time.Duration, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	users, err := database.Collection("users").Distinct(ctx, "_id", bson.M{"username": username})
+	if err != nil || len(users) == 0 {
+		return time.Since(now), err
+	}
+
+	err = deleteMongoProjectsWithReferencing(ctx, database, bson.M{"owner": bson.M{"$in": users}})
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	_, err = database.Collection("users").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": users}})
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+func deleteMongoProjectsWithReferencing(ctx context.Context, database *mongo.Database, filter bson.M) (err error) {
+	cursor, err := database.Collection("projects").Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1, "owner": 1}))
+	if err != nil {
+		return err
+	}
+
+	var projects []struct {
+		Id    any `bson:"_id"`
+		Owner any `bson:"owner"`
+	}
+	err = cursor.All(ctx, &projects)
+	if err != nil {
+		return err
+	}
+	if len(projects) == 0 {
+		return nil
+	}
+
+	ids := make(bson.A, 0, len(projects))
+	owners := make(bson.A, 0, len(projects))
+	for _, project := range projects {
+		ids = append(ids, project.Id)
+		owners = append(owners, project.Owner)
+	}
+
+	_, err = database.Collection("sprints").DeleteMany(ctx, bson.M{"project": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("projects").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+
+	remaining, err := database.Collection("projects").Distinct(ctx, "owner", bson.M{"owner": bson.M{"$in": owners}})
+	if err != nil {
+		return err
+	}
+
+	// $nin needs an array even when no owner has a project left.
+	_, err = database.Collection("users").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": owners, "$nin": append(bson.A{}, remaining...)}})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/* SCHEMA EVOLUTION */
+
+// Every variant gets a new project attribute, status, which is backfilled
+// for the existing projects. The relational models also add a second one,
+// priority, with a default, which Postgres only records in the catalog. The
+// Mongo models have nothing to add but change their validator to require
+// the new attribute.
+
+// evolutionSteps are the steps of the schema change in the order they run.
+// "No change" runs nothing and shows how slow the concurrent reads are on
+// their own.
+var evolutionSteps = []string{"No change", "Add column", "Add column with default", "Backfill", "Update validator"}
+
+// evolutionPause is how long "No change" lets the concurrent reads run.
+const evolutionPause = 100 * time.Millisecond
+
+// EvolutionFuncs change the schema of the projects of a variant.
+type EvolutionFuncs struct {
+	// Steps runs the steps of evolutionSteps the variant supports, keyed by
+	// step name.
+	Steps map[string]func() (time.Duration, error)
+	// Revert takes the variant back to the schema it started with.
+	Revert func() error
+	// Size returns the bytes the projects take up.
+	Size func() (int64, error)
+}
+
+// SweepEvolution fills every variant with projects of each size and runs the
+// steps of the schema change on it, while another goroutine keeps reading
+// a project to find out how long the steps block reads. It reports the
+// duration of every step, the slowest concurrent read and how much the
+// projects grew, then reverts the change. It returns the table rows and the
+// chart series of every step keyed by variant name.
+func SweepEvolution(variants []Variant, sizes []int) (rows [][]string, data map[string]map[string][]opts.LineData, err error) {
+	data = make(map[string]map[string][]opts.LineData)
+	for _, step := range evolutionSteps {
+		data[step] = make(map[string][]opts.LineData)
+	}
+
+	for _, size := range sizes {
+		projects := GenerateProjects(size)
+		err = FillVariants(variants, projects)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// cells holds the cell of every step and of the storage growth of
+		// every variant.
+		cells := make(map[string][]string)
+		for _, variant := range variants {
+			evolution := variant.Evolution
+			if evolution.Steps == nil {
+				continue
+			}
+
+			before, err := evolution.Size()
+			if err != nil {
+				return nil, nil, fmt.Errorf("sizing %s: %w", variant.Name, err)
+			}
+
+			read := func() (Measurement, error) {
+				return variant.Sprints.Read(projects[0].Identifier)
+			}
+			for _, step := range evolutionSteps {
+				run, ok := evolution.Steps[step]
+				if !ok {
+					cells[variant.Name] = append(cells[variant.Name], "-")
+					continue
+				}
+
+				duration, reads, slowest, err := probeReads(read, run)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s on %s at size %d: %w", step, variant.Name, size, err)
+				}
+				cells[variant.Name] = append(cells[variant.Name], fmt.Sprintf("%s (%d reads, slowest %s)", duration, reads, slowest))
+				data[step][variant.Name] = append(data[step][variant.Name], opts.LineData{Value: float64(duration.Microseconds()) / 1000})
+			}
+
+			after, err := evolution.Size()
+			if err != nil {
+				return nil, nil, fmt.Errorf("sizing %s: %w", variant.Name, err)
+			}
+			cells[variant.Name] = append(cells[variant.Name], fmt.Sprintf("%s → %s", FormatBytes(before), FormatBytes(after)))
+
+			err = evolution.Revert()
+			if err != nil {
+				return nil, nil, fmt.Errorf("reverting the schema of %s: %w", variant.Name, err)
+			}
+		}
+
+		for i, step := range append(evolutionSteps, "Storage") {
+			row := []string{fmt.Sprint(size), step}
+			for _, variant := range variants {
+				if variantCells, ok := cells[variant.Name]; ok {
+					row = append(row, variantCells[i])
+				} else {
+					row = append(row, "-")
+				}
+			}
+			rows = append(rows, row)
+		}
+		rows = append(rows, []string{})
+	}
+
+	return rows, data, nil
+}
+
+// probeReads keeps reading while run runs and returns how long run took, how
+// many reads overlapped it and how long the slowest of those took. run waits
+// for the first read to start, and a read still waiting when run returns is
+// awaited, since it is the one most likely held up by a lock.
+func probeReads(read func() (Measurement, error), run func() (time.Duration, error)) (duration time.Duration, reads int, slowest time.Duration, err error) {
+	type interval struct{ start, end time.Time }
+	var intervals []interval
+	started := make(chan struct{})
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		close(started)
+		for {
+			start := time.Now()
+			_, err := read()
+			if err != nil {
+				done <- err
+				return
+			}
+			intervals = append(intervals, interval{start, time.Now()})
+
+			select {
+			case <-stop:
+				done <- nil
+				return
+			default:
+			}
+		}
+	}()
+
+	<-started
+	start := time.Now()
+	duration, err = run()
+	end := time.Now()
+	close(stop)
+	readErr := <-done
+	if err != nil {
+		return duration, 0, 0, err
+	}
+
+	for _, read := range intervals {
+		if read.end.After(start) && read.start.Before(end) {
+			reads++
+			slowest = max(slowest, read.end.Sub(read.start))
+		}
+	}
+	return duration, reads, slowest, readErr
+}
+
+// pause is the "No change" step.
+func pause() (time.Duration, error) {
+	time.Sleep(evolutionPause)
+	return evolutionPause, nil
+}
+
+/* POSTGRES */
+
+func EvolvePostgres(conn *pgxpool.Pool) EvolutionFuncs {
+	exec := func(query string) func() (time.Duration, error) {
+		return func() (time.Duration, error) {
+			now := time.Now()
+			_, err := conn.Exec(context.Background(), query)
+			return time.Since(now), err
+		}
+	}
+
+	return EvolutionFuncs{
+		Steps: map[string]func() (time.Duration, error){
+			"No change":               pause,
+			"Add column":              exec(`ALTER TABLE projects ADD COLUMN status VARCHAR(16);`),
+			"Add column with default": exec(`ALTER TABLE projects ADD COLUMN priority INT NOT NULL DEFAULT 0;`),
+			"Backfill":                exec(`UPDATE projects SET status = 'active';`),
+		},
+		Revert: func() error {
+			_, err := conn.Exec(context.Background(), `ALTER TABLE projects DROP COLUMN IF EXISTS status, DROP COLUMN IF EXISTS priority;`)
+			return err
+		},
+		// Dead row versions the backfill leaves behind count until vacuum.
+		Size: func() (size int64, err error) {
+			err = conn.QueryRow(context.Background(), `SELECT pg_total_relation_size('projects');`).Scan(&size)
+			return size, err
+		},
+	}
+}
+
+/* SQLITE */
+
+func EvolveSqlite(conn *sql.DB) EvolutionFuncs {
+	exec := func(query string) func() (time.Duration, error) {
+		return func() (time.Duration, error) {
+			now := time.Now()
+			_, err := conn.ExecContext(context.Background(), query)
+			return time.Since(now), err
+		}
+	}
+
+	return EvolutionFuncs{
+		Steps: map[string]func() (time.Duration, error){
+			"No change":               pause,
+			"Add column":              exec(`ALTER TABLE projects ADD COLUMN status VARCHAR(16);`),
+			"Add column with default": exec(`ALTER TABLE projects ADD COLUMN priority INT NOT NULL DEFAULT 0;`),
+			"Backfill":                exec(`UPDATE projects SET status = 'active';`),
+		},
+		Revert: func() error {
+			_, err := conn.ExecContext(context.Background(), `ALTER TABLE projects DROP COLUMN status; ALTER TABLE projects DROP COLUMN priority;`)
+			return err
+		},
+		// The pages of the table and its indexes, like pg_total_relation_size.
+		Size: func() (size int64, err error) {
+			err = conn.QueryRowContext(context.Background(), `SELECT SUM(pgsize) FROM dbstat WHERE name IN (SELECT name FROM sqlite_schema WHERE tbl_name = 'projects');`).Scan(&size)
+			return size, err
+		},
+	}
+}
+
+/* MONGODB */
+
+// EvolveMongo backfills the projects in the collection and makes its
+// validator require the new attribute on top of whatever it checked before.
+func EvolveMongo(database *mongo.Database, collection string) EvolutionFuncs {
+	var validator any
+	return EvolutionFuncs{
+		Steps: map[string]func() (time.Duration, error){
+			"No change": pause,
+			"Backfill": func() (time.Duration, error) {
+				now := time.Now()
+				_, err := database.Collection(collection).UpdateMany(context.Background(), bson.M{}, bson.M{"$set": bson.M{"status": "active"}})
+				return time.Since(now), err
+			},
+			"Update validator": func() (time.Duration, error) {
+				now := time.Now()
+				ctx := context.Background()
+				var err error
+				validator, err = mongoValidator(ctx, database, collection)
+				if err != nil {
+					return time.Since(now), err
+				}
+
+				status := bson.M{"$jsonSchema": bson.M{
+					"required":   bson.A{"status"},
+					"properties": bson.M{"status": bson.M{"bsonType": "string"}},
+				}}
+				if validator != nil {
+					status = bson.M{"$and": bson.A{validator, status}}
+				}
+				err = database.RunCommand(ctx, bson.D{{Key: "collMod", Value: collection}, {Key: "validator", Value: status}}).Err()
+				return time.Since(now), err
+			},
+		},
+		Revert: func() error {
+			ctx := context.Background()
+			previous := validator
+			if previous == nil {
+				previous = bson.M{}
+			}
+			err := database.RunCommand(ctx, bson.D{{Key: "collMod", Value: collection}, {Key: "validator", Value: previous}}).Err()
+			if err != nil {
+				return err
+			}
+			_, err = database.Collection(collection).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"status": ""}})
+			return err
+		},
+		// The uncompressed size of the documents, since the size on disk only
+		// catches up at the next checkpoint.
+		Size: func() (int64, error) {
+			return mongoDataSize(database, collection)
+		},
+	}
+}
+
+// mongoValidator returns the validator of the collection, or nil if it has
+// none.
+func mongoValidator(ctx context.Context, database *mongo.Database, collection string) (validator any, err error) {
+	specifications, err := database.ListCollectionSpecifications(ctx, bson.M{"name": collection})
+	if err != nil {
+		return nil, err
+	}
+	if len(specifications) == 0 || specifications[0].Options == nil {
+		return nil, nil
+	}
+
+	value, err := specifications[0].Options.LookupErr("validator")
+	if err != nil {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// mongoDataSize returns the uncompressed size of the documents of the
+// collection.
+func mongoDataSize(database *mongo.Database, collection string) (size int64, err error) {
+	ctx := context.Background()
+	cursor, err := database.Collection(collection).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$collStats", Value: bson.M{"storageStats": bson.M{}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	if cursor.Next(ctx) {
+		size, _ = cursor.Current.Lookup("storageStats", "size").AsInt64OK()
+	}
+	return size, cursor.Err()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+// growthProjects is the number of projects the growth sweep appends sprints
+// to.
+const growthProjects = 5
+
+// growthOperations are the rows of every step of the growth sweep.
+var growthOperations = []string{"Append sprint", "Update sprint", "Read project"}
+
+// GrowthSprint returns the sprint the growth sweep appends to a project as
+// its nth one, following the sprint before it.
+func GrowthSprint(project models.Project, n int) models.Sprint {
+	length := int64(project.SprintDuration) * (60 * 60 * 24)
+	startDate := project.Sprints[0].StartDate + int64(n-1)*length
+	return models.Sprint{
+		Name:      fmt.Sprintf("%s-%d", project.Name, n),
+		StartDate: startDate,
+		EndDate:   startDate + length,
+	}
+}
+
+// GrowthLimit records a variant that stopped growing because a project
+// document outgrew the BSON size limit.
+type GrowthLimit struct {
+	Variant string
+	Sprints int
+	Err     error
+}
+
+// isBSONLimit reports whether a write failed because the document it
+// produced would be larger than 16 MiB.
+func isBSONLimit(err error) bool {
+	if errors.Is(err, driver.ErrDocumentTooLarge) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		// BSONObjectTooLarge and the two codes for updates that would
+		// produce a document that is too large.
+		return serverErr.HasErrorCode(10334) || serverErr.HasErrorCode(17419) || serverErr.HasErrorCode(17420)
+	}
+	return false
+}
+
+// SweepGrowth fills every variant with a few projects and keeps appending
+// sprints to all of them until every project holds as many sprints as the
+// step says. At every step it reports the mean latency of the appends that
+// got there, and of updating a sprint by name and reading a whole project at
+// that size, so that growing embedded arrays can be compared with growing
+// tables and collections. A variant whose documents hit the BSON size limit
+// stops growing and is returned among the limits, the others carry on. It
+// returns the table rows, the chart series of every operation keyed by
+// variant name and, if no variant hit the limit, the variants whose state
+// differs from the first one's after the last step.
+func SweepGrowth(variants []Variant, steps []int) (rows [][]string, data map[string]map[string][]opts.LineData, limits []GrowthLimit, mismatches []Mismatch, err error) {
+	projects := GenerateProjects(growthProjects)
+	err = FillVariants(variants, projects)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	data = make(map[string]map[string][]opts.LineData)
+	for _, operation := range growthOperations {
+		data[operation] = make(map[string][]opts.LineData)
+	}
+
+	// cells holds the cell of every step and operation of every variant.
+	cells := make(map[string][][]string)
+	for _, variant := range variants {
+		if variant.Sprints.Add == nil {
+			continue
+		}
+
+		// Every generated project starts out with one sprint.
+		current := 1
+		for _, step := range steps {
+			var results []time.Duration
+			var read Measurement
+			results, read, err = growStep(variant.Sprints, projects, current, step)
+			if isBSONLimit(err) {
+				log.Printf("%s stopped growing beyond %d sprints per project: %v", variant.Name, current, err)
+				limits = append(limits, GrowthLimit{variant.Name, current, err})
+				break
+			}
+			if err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("growing %s to %d sprints: %w", variant.Name, step, err)
+			}
+			current = step
+
+			stepCells := make([]string, 0, len(growthOperations))
+			for i, operation := range growthOperations {
+				cell := results[i].String()
+				if operation == "Read project" {
+					cell = fmt.Sprintf("%s (%s)", results[i], FormatBytes(read.Bytes))
+				}
+				stepCells = append(stepCells, cell)
+				data[operation][variant.Name] = append(data[operation][variant.Name], opts.LineData{Value: float64(results[i].Microseconds()) / 1000})
+			}
+			cells[variant.Name] = append(cells[variant.Name], stepCells)
+		}
+	}
+
+	limited := make(map[string]bool)
+	for _, limit := range limits {
+		limited[limit.Variant] = true
+	}
+
+	for i, step := range steps {
+		for j, operation := range growthOperations {
+			row := []string{fmt.Sprint(step), operation}
+			for _, variant := range variants {
+				if i < len(cells[variant.Name]) {
+					row = append(row, cells[variant.Name][i][j])
+				} else if limited[variant.Name] && i == len(cells[variant.Name]) {
+					row = append(row, "BSON limit")
+				} else {
+					row = append(row, "-")
+				}
+			}
+			rows = append(rows, row)
+		}
+		rows = append(rows, []string{})
+	}
+
+	if len(limits) == 0 {
+		states, err := States(variants)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		mismatches = VerifyStates(steps[len(steps)-1], "Growth", variants, states)
+	}
+
+	return rows, data, limits, mismatches, nil
+}
+
+// growStep appends sprints to every project until each holds step sprints,
+// then updates the first sprint of every project and reads every project.
+// It returns the mean duration of each of the growth operations and the last
+// read.
+func growStep(sprints SprintFuncs, projects []models.Project, current int, step int) (means []time.Duration, read Measurement, err error) {
+	var appended, updated, readTotal time.Duration
+	appends := 0
+	for n := current + 1; n <= step; n++ {
+		for _, project := range projects {
+			duration, err := sprints.Add(project.Identifier, GrowthSprint(project, n))
+			if err != nil {
+				return nil, read, err
+			}
+			appended += duration
+			appends++
+		}
+	}
+
+	for _, project := range projects {
+		duration, err := sprints.Update(project.Identifier, project.Sprints[0].Name)
+		if err != nil {
+			return nil, read, err
+		}
+		updated += duration
+
+		read, err = sprints.Read(project.Identifier)
+		if err != nil {
+			return nil, read, err
+		}
+		readTotal += read.Duration
+	}
+
+	means = []time.Duration{0, updated / time.Duration(len(projects)), readTotal / time.Duration(len(projects))}
+	if appends > 0 {
+		means[0] = appended / time.Duration(appends)
+	}
+	return means, read, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+func TestIsBSONLimit(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		err   error
+		limit bool
+	}{
+		{"BSONObjectTooLarge", mongo.CommandError{Code: 10334, Message: "BSONObjectTooLarge"}, true},
+		{"update too large", mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 17419}}}, true},
+		{"update result too large", mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 17420}}}, true},
+		{"document too large", fmt.Errorf("appending: %w", driver.ErrDocumentTooLarge), true},
+		{"other server error", mongo.CommandError{Code: 11000, Message: "duplicate key"}, false},
+		{"other error", errors.New("connection reset"), false},
+		{"no error", nil, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isBSONLimit(test.err); got != test.limit {
+				t.Errorf("isBSONLimit(%v) = %v, want %v", test.err, got, test.limit)
+			}
+		})
+	}
+}
+
+// TestSweepGrowthStopsAtLimit grows a variant whose appends fail with the
+// BSON limit beyond a number of sprints next to one that keeps growing.
+func TestSweepGrowthStopsAtLimit(t *testing.T) {
+	const maxSprints = 50
+	steps := []int{10, 100, 200}
+
+	limited := openGrowthVariant(t, "Limited")
+	sprints := make(map[string]int)
+	add := limited.Sprints.Add
+	limited.Sprints.Add = func(identifier string, sprint models.Sprint) (time.Duration, error) {
+		if sprints[identifier] == maxSprints {
+			return 0, mongo.CommandError{Code: 10334, Message: "BSONObjectTooLarge"}
+		}
+		sprints[identifier]++
+		return add(identifier, sprint)
+	}
+	unlimited := openGrowthVariant(t, "Unlimited")
+
+	rows, _, limits, _, err := SweepGrowth([]Variant{limited, unlimited}, steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(limits) != 1 || limits[0].Variant != "Limited" || limits[0].Sprints != steps[0] || !isBSONLimit(limits[0].Err) {
+		t.Fatalf("limits = %+v, want Limited at %d sprints", limits, steps[0])
+	}
+
+	// Every step has a row per growth operation and an empty one. The
+	// limited variant has a duration for the first step only.
+	measured := func(cell string) bool { return cell != "BSON limit" && cell != "-" }
+	for i, want := range []string{"a duration", "BSON limit", "-"} {
+		for j := range growthOperations {
+			row := rows[i*(len(growthOperations)+1)+j]
+			if i == 0 && !measured(row[2]) || i > 0 && row[2] != want {
+				t.Errorf("Limited at %s sprints, %s = %q, want %s", row[0], row[1], row[2], want)
+			}
+			if !measured(row[3]) {
+				t.Errorf("Unlimited at %s sprints, %s = %q, want a duration", row[0], row[1], row[3])
+			}
+		}
+	}
+}
+
+func openGrowthVariant(t *testing.T, name string) Variant {
+	t.Helper()
+
+	conn, err := ConnectSqlite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	err = InitializeSqlite(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variant := SqliteVariant(conn)
+	variant.Name = name
+	return variant
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -168,13 +169,18 @@ func AddMongoProject(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		panic(err)
 	}
+
+	ownerOid, err := primitive.ObjectIDFromHex(ownerId)
+	if err != nil {
+		panic(err)
+	}
 	
-	_, err = db.MongoConn.Database("test").Collection("projects").InsertOne(context.Background(), bson.M{
+	_, err = db.MongoConn.Database(db.MongoDatabase).Collection("projects").InsertOne(context.Background(), bson.M{
 		"name": name,
 		"identifier": identifier,
 		"invite_code": inviteCode,
 		"sprint_duration": duration,
-		"owner_id": ownerId,
+		"owner_id": ownerOid,
 	})
 
 	if err != nil {
@@ -189,12 +195,12 @@ func GetMongoProjects(nameSearch string) (projects [][]string) {
 	var err error
 
 	if nameSearch != "" {
-		cursor, err = db.MongoConn.Database("test").Collection("projects").Find(context.Background(), bson.M{"name": bson.M{"$regex": nameSearch}})
+		cursor, err = db.MongoConn.Database(db.MongoDatabase).Collection("projects").Find(context.Background(), bson.M{"name": bson.M{"$regex": nameSearch}})
 		if err != nil {
 			panic(err)
 		}
 	} else {
-		cursor, err = db.MongoConn.Database("test").Collection("projects").Find(context.Background(), bson.M{})
+		cursor, err = db.MongoConn.Database(db.MongoDatabase).Collection("projects").Find(context.Background(), bson.M{})
 		if err != nil {
 			panic(err)
 		}
@@ -222,7 +228,7 @@ func GetMongoProject(id string) (project models.Project) {
 		panic(err)
 	}
 
-	result := db.MongoConn.Database("test").Collection("projects").FindOne(context.Background(), bson.M{"_id": oid})
+	result := db.MongoConn.Database(db.MongoDatabase).Collection("projects").FindOne(context.Background(), bson.M{"_id": oid})
 
 	err = result.Decode(&project)
 	if err != nil {
@@ -245,7 +251,7 @@ func DeleteMongoProject(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	_, err = db.MongoConn.Database("test").Collection("projects").DeleteOne(context.Background(), bson.M{"_id": oid})
+	_, err = db.MongoConn.Database(db.MongoDatabase).Collection("projects").DeleteOne(context.Background(), bson.M{"_id": oid})
 
 	if err != nil {
 		panic(err)
@@ -272,8 +278,13 @@ func UpdateMongoProject(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	_, err = db.MongoConn.Database("test").Collection("projects").UpdateOne(context.Background(), bson.M{"_id": oid}, bson.M{"$set": bson.M{
-		"owner_id": r.PostFormValue("owner_id"),
+	ownerOid, err := primitive.ObjectIDFromHex(r.PostFormValue("owner_id"))
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.MongoConn.Database(db.MongoDatabase).Collection("projects").UpdateOne(context.Background(), bson.M{"_id": oid}, bson.M{"$set": bson.M{
+		"owner_id": ownerOid,
 		"name": r.PostFormValue("name"),
 		"identifier": r.PostFormValue("identifier"),
 		"invite_code": r.PostFormValue("invite_code"),
@@ -285,4 +296,108 @@ func UpdateMongoProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	http.Redirect(w, r, "/mongo", http.StatusTemporaryRedirect)
-}	
\ No newline at end of file
+}	
+/* SQLITE */
+
+func AddSqliteProject(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	query := `insert into projects (name, identifier, invite_code, sprint_duration, owner_id) values (@name, @identifier, @invite_code, @sprint_duration, @owner_id)`
+	_, err = db.SqliteConn.ExecContext(context.Background(), query,
+		sql.Named("name", r.PostFormValue("name")),
+		sql.Named("identifier", r.PostFormValue("identifier")),
+		sql.Named("invite_code", r.PostFormValue("invite_code")),
+		sql.Named("sprint_duration", r.PostFormValue("sprint_duration")),
+		sql.Named("owner_id", r.PostFormValue("owner_id")),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
+
+func GetSqliteProjects(nameSearch string) (projects [][]string) {
+	var rows *sql.Rows
+	var err error
+
+	if nameSearch != "" {
+		rows, err = db.SqliteConn.QueryContext(context.Background(), `select id, name, identifier, invite_code, sprint_duration, owner_id from projects where name like ?`, nameSearch)
+	} else {
+		rows, err = db.SqliteConn.QueryContext(context.Background(), `select id, name, identifier, invite_code, sprint_duration, owner_id from projects`)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		var identifier string
+		var inviteCode string
+		var sprintDuration int
+		var ownerId int
+		err = rows.Scan(&id, &name, &identifier, &inviteCode, &sprintDuration, &ownerId)
+		if err != nil {
+			panic(err)
+		}
+
+		projects = append(projects, []string{strconv.Itoa(id), name, identifier, inviteCode, strconv.Itoa(sprintDuration), strconv.Itoa(ownerId)})
+	}
+
+	return projects
+}
+
+func GetSqliteProject(id int) (project models.Project) {
+	err := db.SqliteConn.QueryRowContext(context.Background(), `select id, name, identifier, invite_code, sprint_duration, owner_id from projects where id = ?`, id).Scan(&project.Id, &project.Name, &project.Identifier, &project.InviteCode, &project.SprintDuration, &project.OwnerId)
+	if err != nil {
+		panic(err)
+	}
+
+	return project
+}
+
+func DeleteSqliteProject(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.SqliteConn.ExecContext(context.Background(), `delete from projects where id = @id`, sql.Named("id", r.PostFormValue("id")))
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
+
+func UpdateSqliteProject(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	query := `update projects set name = @name, identifier = @identifier, invite_code = @invite_code, sprint_duration = @sprint_duration, owner_id = @owner_id where id = @id`
+	_, err = db.SqliteConn.ExecContext(context.Background(), query,
+		sql.Named("id", r.PostFormValue("id")),
+		sql.Named("name", r.PostFormValue("name")),
+		sql.Named("identifier", r.PostFormValue("identifier")),
+		sql.Named("invite_code", r.PostFormValue("invite_code")),
+		sql.Named("sprint_duration", r.PostFormValue("sprint_duration")),
+		sql.Named("owner_id", r.PostFormValue("owner_id")),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
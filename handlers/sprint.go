@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -41,7 +42,7 @@ func AddSprint(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetSprints() (sprints [][]string) {
-	rows, err := db.PostgresConn.Query(context.Background(), `select * from sprints`)
+	rows, err := db.PostgresConn.Query(context.Background(), `select id, name, start_date, end_date, project_id from sprints`)
 
 	if err != nil {
 		panic(err)
@@ -67,7 +68,7 @@ func GetSprints() (sprints [][]string) {
 }
 
 func GetSprint(id int) (sprint models.Sprint) {
-	err := db.PostgresConn.QueryRow(context.Background(), `select * from sprints where id = $1`, id).Scan(&sprint.Id, &sprint.Name, &sprint.StartDate, &sprint.EndDate, &sprint.ProjectId)
+	err := db.PostgresConn.QueryRow(context.Background(), `select id, name, start_date, end_date, project_id from sprints where id = $1`, id).Scan(&sprint.Id, &sprint.Name, &sprint.StartDate, &sprint.EndDate, &sprint.ProjectId)
 	if err != nil {
 		panic(err)
 	}
@@ -144,11 +145,16 @@ func AddMongoSprint(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-    _, err = db.MongoConn.Database("test").Collection("sprints").InsertOne(context.Background(), bson.M{
+	projectOid, err := primitive.ObjectIDFromHex(projectId)
+	if err != nil {
+		panic(err)
+	}
+
+    _, err = db.MongoConn.Database(db.MongoDatabase).Collection("sprints").InsertOne(context.Background(), bson.M{
         "name": name,
         "start_date": startDateInt,
         "end_date": endDateInt,
-		"project_id": projectId,
+		"project_id": projectOid,
     })
 
     if err != nil {
@@ -159,7 +165,7 @@ func AddMongoSprint(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetMongoSprints() (sprints [][]string) {
-	cursor, err := db.MongoConn.Database("test").Collection("sprints").Find(context.Background(), bson.M{})
+	cursor, err := db.MongoConn.Database(db.MongoDatabase).Collection("sprints").Find(context.Background(), bson.M{})
 	if err != nil {
 		panic(err)
 	}
@@ -187,7 +193,7 @@ func GetMongoSprint(id string) (sprint models.Sprint) {
 		panic(err)
 	}
 
-	result := db.MongoConn.Database("test").Collection("sprints").FindOne(ctx, bson.M{"_id": oid})
+	result := db.MongoConn.Database(db.MongoDatabase).Collection("sprints").FindOne(ctx, bson.M{"_id": oid})
 
 	err = result.Decode(&sprint)
 	if err != nil {
@@ -210,7 +216,7 @@ func DeleteMongoSprint(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	_, err = db.MongoConn.Database("test").Collection("sprints").DeleteOne(context.Background(), bson.M{"_id": oid})
+	_, err = db.MongoConn.Database(db.MongoDatabase).Collection("sprints").DeleteOne(context.Background(), bson.M{"_id": oid})
 	if err != nil {
 		panic(err)
 	}
@@ -231,10 +237,20 @@ func UpdateMongoSprint(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	_, err = db.MongoConn.Database("test").Collection("sprints").UpdateOne(context.Background(), bson.M{"_id": oid}, bson.M{"$set": bson.M{
+	startDate, err := strconv.ParseInt(r.PostFormValue("start_date"), 10, 64)
+	if err != nil {
+		panic(err)
+	}
+
+	endDate, err := strconv.ParseInt(r.PostFormValue("end_date"), 10, 64)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.MongoConn.Database(db.MongoDatabase).Collection("sprints").UpdateOne(context.Background(), bson.M{"_id": oid}, bson.M{"$set": bson.M{
 		"name": r.PostFormValue("name"),
-		"start_date": r.PostFormValue("start_date"),
-		"end_date": r.PostFormValue("end_date"),
+		"start_date": startDate,
+		"end_date": endDate,
 	}})
 
 	if err != nil {
@@ -242,4 +258,96 @@ func UpdateMongoSprint(w http.ResponseWriter, r *http.Request) {
 	}
 
 	http.Redirect(w, r, "/mongo", http.StatusTemporaryRedirect)
-}	
\ No newline at end of file
+}	
+/* SQLITE */
+
+func AddSqliteSprint(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	query := `insert into sprints (name, start_date, end_date, project_id) values (@name, @start_date, @end_date, @project_id)`
+	_, err = db.SqliteConn.ExecContext(context.Background(), query,
+		sql.Named("name", r.PostFormValue("name")),
+		sql.Named("start_date", r.PostFormValue("start_date")),
+		sql.Named("end_date", r.PostFormValue("end_date")),
+		sql.Named("project_id", r.PostFormValue("project_id")),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
+
+func GetSqliteSprints() (sprints [][]string) {
+	rows, err := db.SqliteConn.QueryContext(context.Background(), `select id, name, start_date, end_date, project_id from sprints`)
+	if err != nil {
+		panic(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		var startDate int64
+		var endDate int64
+		var projectId int
+		err = rows.Scan(&id, &name, &startDate, &endDate, &projectId)
+		if err != nil {
+			panic(err)
+		}
+
+		sprints = append(sprints, []string{strconv.Itoa(id), name, strconv.FormatInt(startDate, 10), strconv.FormatInt(endDate, 10), strconv.Itoa(projectId)})
+	}
+
+	return sprints
+}
+
+func GetSqliteSprint(id int) (sprint models.Sprint) {
+	err := db.SqliteConn.QueryRowContext(context.Background(), `select id, name, start_date, end_date, project_id from sprints where id = ?`, id).Scan(&sprint.Id, &sprint.Name, &sprint.StartDate, &sprint.EndDate, &sprint.ProjectId)
+	if err != nil {
+		panic(err)
+	}
+
+	return sprint
+}
+
+func DeleteSqliteSprint(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.SqliteConn.ExecContext(context.Background(), `delete from sprints where id = @id`, sql.Named("id", r.PostFormValue("id")))
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
+
+func UpdateSqliteSprint(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	query := `update sprints set name = @name, start_date = @start_date, end_date = @end_date where id = @id`
+	_, err = db.SqliteConn.ExecContext(context.Background(), query,
+		sql.Named("id", r.PostFormValue("id")),
+		sql.Named("name", r.PostFormValue("name")),
+		sql.Named("start_date", r.PostFormValue("start_date")),
+		sql.Named("end_date", r.PostFormValue("end_date")),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -131,7 +132,7 @@ func AddMongoUser(w http.ResponseWriter, r *http.Request) {
 	firstname := r.PostFormValue("firstName")
 	lastname := r.PostFormValue("lastName")
 
-	_, err = db.MongoConn.Database("test").Collection("users").InsertOne(context.Background(), bson.M{
+	_, err = db.MongoConn.Database(db.MongoDatabase).Collection("users").InsertOne(context.Background(), bson.M{
 		"username": username,
 		"first_name": firstname,
 		"last_name": lastname,
@@ -145,7 +146,7 @@ func AddMongoUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetMongoUsers() (users [][]string) {
-	cursor, err := db.MongoConn.Database("test").Collection("users").Find(context.Background(), bson.M{})
+	cursor, err := db.MongoConn.Database(db.MongoDatabase).Collection("users").Find(context.Background(), bson.M{})
 	if err != nil {
 		panic(err)
 	}
@@ -172,7 +173,7 @@ func GetMongoUser(id string) (user models.User) {
 		panic(err)
 	}
 
-	result := db.MongoConn.Database("test").Collection("users").FindOne(ctx, bson.M{"_id": oid})
+	result := db.MongoConn.Database(db.MongoDatabase).Collection("users").FindOne(ctx, bson.M{"_id": oid})
 
 	err = result.Decode(&user)
 	if err != nil {
@@ -195,7 +196,7 @@ func DeleteMongoUser(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	_, err = db.MongoConn.Database("test").Collection("users").DeleteOne(context.Background(), bson.M{"_id": oid})
+	_, err = db.MongoConn.Database(db.MongoDatabase).Collection("users").DeleteOne(context.Background(), bson.M{"_id": oid})
 
 	if err != nil {
 		panic(err)
@@ -217,7 +218,7 @@ func UpdateMongoUser(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	_, err = db.MongoConn.Database("test").Collection("users").UpdateOne(context.Background(), bson.M{"_id": oid}, bson.M{"$set": bson.M{
+	_, err = db.MongoConn.Database(db.MongoDatabase).Collection("users").UpdateOne(context.Background(), bson.M{"_id": oid}, bson.M{"$set": bson.M{
 		"username": r.PostFormValue("username"),
 		"first_name": r.PostFormValue("firstName"),
 		"last_name": r.PostFormValue("lastName"),
@@ -228,4 +229,94 @@ func UpdateMongoUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	http.Redirect(w, r, "/mongo", http.StatusTemporaryRedirect)
-}	
\ No newline at end of file
+}	
+/* SQLITE */
+
+func AddSqliteUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	query := `insert into users (username, first_name, last_name) values (@username, @firstName, @lastName)`
+	_, err = db.SqliteConn.ExecContext(context.Background(), query,
+		sql.Named("username", r.PostFormValue("username")),
+		sql.Named("firstName", r.PostFormValue("firstName")),
+		sql.Named("lastName", r.PostFormValue("lastName")),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
+
+func GetSqliteUsers() (users [][]string) {
+	rows, err := db.SqliteConn.QueryContext(context.Background(), `select id, username, first_name, last_name from users`)
+	if err != nil {
+		panic(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var username string
+		var firstname string
+		var lastname string
+		err = rows.Scan(&id, &username, &firstname, &lastname)
+		if err != nil {
+			panic(err)
+		}
+
+		users = append(users, []string{strconv.Itoa(id), username, firstname, lastname})
+	}
+
+	return users
+}
+
+func GetSqliteUser(id int) (user models.User) {
+	err := db.SqliteConn.QueryRowContext(context.Background(), `select id, username, first_name, last_name from users where id = ?`, id).Scan(&user.Id, &user.Username, &user.FirstName, &user.LastName)
+	if err != nil {
+		panic(err)
+	}
+
+	return user
+}
+
+func DeleteSqliteUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.SqliteConn.ExecContext(context.Background(), `delete from users where id = @id`, sql.Named("id", r.PostFormValue("id")))
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
+
+func UpdateSqliteUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+
+	query := `update users set username = @username, first_name = @firstname, last_name = @lastname where id = @id`
+	_, err = db.SqliteConn.ExecContext(context.Background(), query,
+		sql.Named("id", r.PostFormValue("id")),
+		sql.Named("username", r.PostFormValue("username")),
+		sql.Named("firstname", r.PostFormValue("firstName")),
+		sql.Named("lastname", r.PostFormValue("lastName")),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+}
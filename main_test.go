@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+// benchVariants are opened once in TestMain and shared by every benchmark.
+var benchVariants []Variant
+
+// TestMain starts the targets before the benchmarks run and stops them, and
+// the containers of the route tests, afterwards. The targets are configured
+// as for main, so
+//
+//	go test -run '^$' -bench . -containers=false -postgres 'name=...,dsn=...'
+//
+// works against remote databases as well. Without -bench no target is
+// opened, which keeps go test usable without Docker.
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	log.SetOutput(redactor.Writer(os.Stderr))
+	HandleInterrupts()
+
+	if flag.Lookup("test.bench").Value.String() != "" {
+		var err error
+		benchVariants, err = OpenVariants()
+		if err != nil {
+			log.Print(err)
+			RunAtExit()
+			os.Exit(1)
+		}
+	}
+
+	code := m.Run()
+	RunAtExit()
+	os.Exit(code)
+}
+
+// BenchmarkOperations runs every operation of every variant as a
+// Backend/Operation/Size sub-benchmark. Reads work on data inserted once per
+// size, while every iteration of a write starts from freshly inserted data so
+// that it always has the same work to do. Setup is not timed.
+func BenchmarkOperations(b *testing.B) {
+	for _, variant := range benchVariants {
+		b.Run(variant.Name, func(b *testing.B) {
+			for _, operation := range operations {
+				run, ok := variant.Operations[operation.Name]
+				if !ok {
+					continue
+				}
+
+				b.Run(operation.Name, func(b *testing.B) {
+					for _, size := range sizes {
+						projects := GenerateProjects(size)
+						b.Run(fmt.Sprint(size), func(b *testing.B) {
+							benchmarkOperation(b, variant, operation, run, projects)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+func benchmarkOperation(b *testing.B, variant Variant, operation Operation, run OperationFunc, projects []models.Project) {
+	seed := func() {
+		err := ResetVariants([]Variant{variant})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if operation.Name != "Insert" {
+			_, err = variant.Operations["Insert"](projects)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		// Remove sprint removes the sprint Add sprint adds.
+		if operation.Name == "Remove sprint" {
+			_, err = variant.Operations["Add sprint"](projects)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.StopTimer()
+	if operation.Read {
+		seed()
+	}
+
+	var result Measurement
+	for i := 0; i < b.N; i++ {
+		if !operation.Read {
+			seed()
+		}
+
+		var err error
+		b.StartTimer()
+		result, err = run(projects)
+		b.StopTimer()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	if operation.Read {
+		b.ReportMetric(float64(result.Rows), "rows/op")
+		b.ReportMetric(float64(result.Bytes), "result-B/op")
+	}
+}
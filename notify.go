@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+/* NOTIFICATIONS */
+
+// notificationWindow is how long sprints are written at every rate.
+const notificationWindow = 2 * time.Second
+
+// notificationGrace is how long notifications may take to arrive after the
+// last write before they count as missed.
+const notificationGrace = 2 * time.Second
+
+// SubscribeFunc subscribes to the sprints added to a variant. The returned
+// channel yields the name of every added sprint as its notification arrives
+// and is closed by unsubscribe.
+type SubscribeFunc func() (names <-chan string, unsubscribe func() error, err error)
+
+// NotificationResult is the outcome of writing sprints at one rate while
+// subscribed to them. Latencies run from the start of a write to the arrival
+// of its notification.
+type NotificationResult struct {
+	Sent       int
+	Missed     int
+	Duplicated int
+	P50        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+// notificationMeasures are the rows of every rate in the notification table.
+var notificationMeasures = []string{"p50", "p99", "max", "missed / sent", "duplicated"}
+
+func (result NotificationResult) cells() []string {
+	return []string{
+		result.P50.String(),
+		result.P99.String(),
+		result.Max.String(),
+		fmt.Sprintf("%d / %d", result.Missed, result.Sent),
+		fmt.Sprint(result.Duplicated),
+	}
+}
+
+// BenchmarkNotifications subscribes to the sprints of every variant that
+// supports it and adds sprints to a project at each rate, in writes per
+// second, to find out how long notifications take and whether any get lost
+// or arrive twice. A variant that cannot subscribe, such as Mongo without a
+// replica set, is logged and left out; change streams run on the targets
+// reserved for notifications, like the default "Mongo (rs)" replica set,
+// which no other benchmark measures. It returns the table rows and the p99
+// latency chart series keyed by variant name.
+func BenchmarkNotifications(variants []Variant, rates []int) (rows [][]string, data map[string][]opts.LineData, err error) {
+	projects := GenerateProjects(1)
+	err = FillVariants(variants, projects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data = make(map[string][]opts.LineData)
+	results := make(map[string][]NotificationResult)
+	for _, variant := range variants {
+		if variant.Subscribe == nil {
+			continue
+		}
+
+		for _, rate := range rates {
+			result, err := measureNotifications(variant, projects[0], rate)
+			if err != nil {
+				log.Printf("skipping notifications on %s: %v", variant.Name, err)
+				delete(results, variant.Name)
+				delete(data, variant.Name)
+				break
+			}
+
+			results[variant.Name] = append(results[variant.Name], result)
+			data[variant.Name] = append(data[variant.Name], opts.LineData{Value: float64(result.P99.Microseconds()) / 1000})
+		}
+	}
+
+	for i, rate := range rates {
+		for j, measure := range notificationMeasures {
+			row := []string{fmt.Sprint(rate), measure}
+			for _, variant := range variants {
+				if variantResults, ok := results[variant.Name]; ok {
+					row = append(row, variantResults[i].cells()[j])
+				} else {
+					row = append(row, "-")
+				}
+			}
+			rows = append(rows, row)
+		}
+		rows = append(rows, []string{})
+	}
+
+	return rows, data, nil
+}
+
+// measureNotifications adds sprints to the project at the rate for the
+// notification window and collects their notifications.
+func measureNotifications(variant Variant, project models.Project, rate int) (result NotificationResult, err error) {
+	names, unsubscribe, err := variant.Subscribe()
+	if err != nil {
+		return result, err
+	}
+
+	var received atomic.Int64
+	arrivals := make(chan map[string][]time.Time)
+	go func() {
+		arrived := make(map[string][]time.Time)
+		for name := range names {
+			arrived[name] = append(arrived[name], time.Now())
+			received.Add(1)
+		}
+		arrivals <- arrived
+	}()
+
+	// A write that takes longer than the interval delays the ones after it,
+	// so the rate is an upper bound.
+	sent := make(map[string]time.Time)
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+	for i := 0; i < int(notificationWindow.Seconds()*float64(rate)); i++ {
+		<-ticker.C
+		sprint := models.Sprint{
+			Name:      fmt.Sprintf("notify-%d-%d", rate, i),
+			StartDate: project.Sprints[0].StartDate,
+			EndDate:   project.Sprints[0].EndDate,
+		}
+		sent[sprint.Name] = time.Now()
+		_, err = variant.Sprints.Add(project.Identifier, sprint)
+		if err != nil {
+			unsubscribe()
+			<-arrivals
+			return result, err
+		}
+	}
+
+	deadline := time.Now().Add(notificationGrace)
+	for received.Load() < int64(len(sent)) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err = unsubscribe()
+	arrived := <-arrivals
+	if err != nil {
+		return result, err
+	}
+
+	latencies := make([]time.Duration, 0, len(sent))
+	for name, at := range sent {
+		times, ok := arrived[name]
+		if !ok {
+			result.Missed++
+			continue
+		}
+		latencies = append(latencies, times[0].Sub(at))
+		result.Duplicated += len(times) - 1
+	}
+	result.Sent = len(sent)
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		result.P50 = latencies[(len(latencies)+1)/2-1]
+		result.P99 = latencies[(len(latencies)*99+99)/100-1]
+		result.Max = latencies[len(latencies)-1]
+	}
+	return result, nil
+}
+
+/* POSTGRES */
+
+// SubscribePostgres notifies about inserted sprints from a trigger and
+// listens on a connection of its own, on a channel named after the schema.
+// The trigger is dropped again on unsubscribe so that it does not slow down
+// the other benchmarks.
+func SubscribePostgres(conn *pgxpool.Pool) SubscribeFunc {
+	return func() (<-chan string, func() error, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := conn.Exec(ctx, `
+			CREATE OR REPLACE FUNCTION notify_sprint() RETURNS trigger AS $$
+			BEGIN
+				PERFORM pg_notify('sprints_' || TG_TABLE_SCHEMA, NEW.name);
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+
+			DROP TRIGGER IF EXISTS sprints_notify ON sprints;
+			CREATE TRIGGER sprints_notify AFTER INSERT ON sprints FOR EACH ROW EXECUTE FUNCTION notify_sprint();
+		`)
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+
+		dropTrigger := func() error {
+			_, err := conn.Exec(context.Background(), `DROP TRIGGER IF EXISTS sprints_notify ON sprints; DROP FUNCTION IF EXISTS notify_sprint();`)
+			return err
+		}
+
+		listener, err := pgx.ConnectConfig(ctx, conn.Config().ConnConfig.Copy())
+		if err != nil {
+			cancel()
+			return nil, nil, errors.Join(err, dropTrigger())
+		}
+
+		var schema string
+		err = listener.QueryRow(ctx, `SELECT current_schema();`).Scan(&schema)
+		if err == nil {
+			_, err = listener.Exec(ctx, `LISTEN `+pgx.Identifier{"sprints_" + schema}.Sanitize())
+		}
+		if err != nil {
+			cancel()
+			listener.Close(context.Background())
+			return nil, nil, errors.Join(err, dropTrigger())
+		}
+
+		names := make(chan string)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer close(names)
+			for {
+				notification, err := listener.WaitForNotification(ctx)
+				if err != nil {
+					return
+				}
+				names <- notification.Payload
+			}
+		}()
+
+		unsubscribe := func() error {
+			cancel()
+			<-done
+			listener.Close(context.Background())
+			return dropTrigger()
+		}
+		return names, unsubscribe, nil
+	}
+}
+
+/* MONGODB */
+
+// SubscribeMongo opens a change stream on the collection, which only works
+// on a replica set. Sprints show up as inserts into the sprints collection
+// of the referencing model and as pushes onto the sprints array of an
+// embedded project.
+func SubscribeMongo(database *mongo.Database, collection string) SubscribeFunc {
+	return func() (<-chan string, func() error, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := database.Collection(collection).Watch(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update"}}}}},
+		})
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+
+		names := make(chan string)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer close(names)
+			for stream.Next(ctx) {
+				for _, name := range sprintNames(stream.Current) {
+					names <- name
+				}
+			}
+		}()
+
+		unsubscribe := func() error {
+			cancel()
+			<-done
+			return stream.Close(context.Background())
+		}
+		return names, unsubscribe, nil
+	}
+}
+
+// sprintNames returns the names of the sprints a change event added.
+func sprintNames(event bson.Raw) (names []string) {
+	if event.Lookup("operationType").StringValue() == "insert" {
+		if name, ok := event.Lookup("fullDocument", "name").StringValueOK(); ok {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	// A push shows up as the new element, "sprints.4", or as the whole
+	// array when the server decides to replace it. Updated fields of a
+	// sprint, such as "sprints.0.end_date", are not additions.
+	fields, ok := event.Lookup("updateDescription", "updatedFields").DocumentOK()
+	if !ok {
+		return nil
+	}
+	elements, err := fields.Elements()
+	if err != nil {
+		return nil
+	}
+	for _, element := range elements {
+		switch key := element.Key(); {
+		case strings.HasPrefix(key, "sprints.") && strings.Count(key, ".") == 1:
+			sprint, ok := element.Value().DocumentOK()
+			if !ok {
+				continue
+			}
+			if name, ok := sprint.Lookup("name").StringValueOK(); ok {
+				names = append(names, name)
+			}
+		case key == "sprints":
+			values, err := element.Value().Array().Values()
+			if err != nil || len(values) == 0 {
+				continue
+			}
+			sprint, ok := values[len(values)-1].DocumentOK()
+			if !ok {
+				continue
+			}
+			if name, ok := sprint.Lookup("name").StringValueOK(); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
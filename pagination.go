@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+/* PAGINATION */
+
+// pageSize is the number of sprints on a page.
+const pageSize = 50
+
+// PageFunc reads one page of sprints ordered by start date and id, either
+// by skipping the rows of the pages before it or, with keyset, by starting
+// after the last sprint of the previous page.
+type PageFunc func(page int64, keyset bool) (Measurement, error)
+
+// pagePositions are the pages every variant reads, as the fraction of the
+// way through the sprints.
+var pagePositions = []struct {
+	Name     string
+	Fraction float64
+}{
+	{"First", 0},
+	{"Middle", 0.5},
+	{"Last", 1},
+}
+
+// withPages adds an operation for every page position and method to the
+// operations of a variant, for example "Last page with keyset". count returns
+// the number of sprints, which decides where the middle and last pages are,
+// and is not measured.
+func withPages(operations map[string]OperationFunc, count func() (int64, error), page PageFunc) map[string]OperationFunc {
+	for _, keyset := range []bool{false, true} {
+		method := "offset"
+		if keyset {
+			method = "keyset"
+		}
+
+		for _, position := range pagePositions {
+			operations[fmt.Sprintf("%s page with %s", position.Name, method)] = func(projects []models.Project) (Measurement, error) {
+				sprints, err := count()
+				if err != nil {
+					return Measurement{}, err
+				}
+
+				pages := (sprints + pageSize - 1) / pageSize
+				if pages == 0 {
+					return Measurement{}, nil
+				}
+				return page(int64(position.Fraction*float64(pages-1)), keyset)
+			}
+		}
+	}
+	return operations
+}
+
+/* POSTGRES */
+
+func CountPostgresSprints(conn *pgxpool.Pool) (count int64, err error) {
+	err = conn.QueryRow(context.Background(), `SELECT COUNT(*) FROM sprints;`).Scan(&count)
+	return count, err
+}
+
+func PagePostgres(conn *pgxpool.Pool, page int64, keyset bool) (result Measurement, err error) {
+	if !keyset || page == 0 {
+		now := time.Now()
+		result.Rows, result.Bytes, err = queryPostgres(conn, `SELECT * FROM sprints ORDER BY start_date, id LIMIT $1 OFFSET $2;`, pageSize, page*pageSize)
+		result.Duration = time.Since(now)
+		if err != nil {
+			return result, err
+		}
+		return result, nil
+	}
+
+	// The application would remember the last sprint of the previous page.
+	var startDate int64
+	var id int
+	err = conn.QueryRow(context.Background(), `SELECT start_date, id FROM sprints ORDER BY start_date, id LIMIT 1 OFFSET $1;`, page*pageSize-1).Scan(&startDate, &id)
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	result.Rows, result.Bytes, err = queryPostgres(conn, `SELECT * FROM sprints WHERE (start_date, id) > ($1, $2) ORDER BY start_date, id LIMIT $3;`, startDate, id, pageSize)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+/* SQLITE */
+
+func CountSqliteSprints(conn *sql.DB) (count int64, err error) {
+	err = conn.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM sprints;`).Scan(&count)
+	return count, err
+}
+
+func PageSqlite(conn *sql.DB, page int64, keyset bool) (result Measurement, err error) {
+	if !keyset || page == 0 {
+		now := time.Now()
+		result.Rows, result.Bytes, err = querySqlite(conn, `SELECT * FROM sprints ORDER BY start_date, id LIMIT ? OFFSET ?;`, pageSize, page*pageSize)
+		result.Duration = time.Since(now)
+		if err != nil {
+			return result, err
+		}
+		return result, nil
+	}
+
+	// The application would remember the last sprint of the previous page.
+	var startDate int64
+	var id int
+	err = conn.QueryRowContext(context.Background(), `SELECT start_date, id FROM sprints ORDER BY start_date, id LIMIT 1 OFFSET ?;`, page*pageSize-1).Scan(&startDate, &id)
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	result.Rows, result.Bytes, err = querySqlite(conn, `SELECT * FROM sprints WHERE (start_date, id) > (?, ?) ORDER BY start_date, id LIMIT ?;`, startDate, id, pageSize)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+/* MONGODB */
+
+// CountMongoSprints counts the sprints embedded in the projects of the
+// collection.
+func CountMongoSprints(database *mongo.Database, collection string) (count int64, err error) {
+	ctx := context.Background()
+	cursor, err := database.Collection(collection).Aggregate(ctx, []bson.M{
+		{"$group": bson.M{"_id": nil, "count": bson.M{"$sum": bson.M{"$size": "$sprints"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var results []struct {
+		Count int64 `bson:"count"`
+	}
+	err = cursor.All(ctx, &results)
+	if err != nil || len(results) == 0 {
+		return 0, err
+	}
+	return results[0].Count, nil
+}
+
+// PageMongo pages through the embedded sprints, ordered by their start date
+// and the id of their project.
+func PageMongo(database *mongo.Database, collection string, page int64, keyset bool) (result Measurement, err error) {
+	ctx := context.Background()
+	sort := bson.M{"$sort": bson.D{{Key: "sprints.start_date", Value: 1}, {Key: "_id", Value: 1}}}
+
+	// position holds the stages that get from the first sprint to the page.
+	position := []bson.M{sort, {"$skip": page * pageSize}}
+	if keyset && page > 0 {
+		// The application would remember the last sprint of the previous page.
+		var last struct {
+			Id      primitive.ObjectID `bson:"_id"`
+			Sprints models.Sprint      `bson:"sprints"`
+		}
+		cursor, err := database.Collection(collection).Aggregate(ctx, []bson.M{
+			{"$unwind": "$sprints"},
+			sort,
+			{"$skip": page*pageSize - 1},
+			{"$limit": 1},
+		})
+		if err != nil {
+			return result, err
+		}
+		if !cursor.Next(ctx) {
+			cursor.Close(ctx)
+			return result, fmt.Errorf("page %d of %s is out of range", page, collection)
+		}
+		err = cursor.Decode(&last)
+		cursor.Close(ctx)
+		if err != nil {
+			return result, err
+		}
+
+		position = []bson.M{
+			{"$match": bson.M{"$or": bson.A{
+				bson.M{"sprints.start_date": bson.M{"$gt": last.Sprints.StartDate}},
+				bson.M{"sprints.start_date": last.Sprints.StartDate, "_id": bson.M{"$gt": last.Id}},
+			}}},
+			sort,
+		}
+	}
+
+	pipeline := []bson.M{{"$unwind": "$sprints"}}
+	pipeline = append(pipeline, position...)
+	pipeline = append(pipeline, bson.M{"$limit": pageSize}, bson.M{"$replaceRoot": bson.M{"newRoot": "$sprints"}})
+
+	now := time.Now()
+	cursor, err := database.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return result, err
+	}
+	result.Rows, result.Bytes, err = drainCursor(ctx, cursor)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func CountMongoSprintsWithReferencing(database *mongo.Database) (count int64, err error) {
+	return database.Collection("sprints").CountDocuments(context.Background(), bson.M{})
+}
+
+func PageMongoWithReferencing(database *mongo.Database, page int64, keyset bool) (result Measurement, err error) {
+	ctx := context.Background()
+	sort := bson.D{{Key: "start_date", Value: 1}, {Key: "_id", Value: 1}}
+
+	filter := bson.M{}
+	opts := options.Find().SetSort(sort).SetLimit(pageSize).SetSkip(page * pageSize)
+	if keyset && page > 0 {
+		// The application would remember the last sprint of the previous page.
+		var last models.Sprint
+		err = database.Collection("sprints").FindOne(ctx, bson.M{}, options.FindOne().SetSort(sort).SetSkip(page*pageSize-1)).Decode(&last)
+		if err != nil {
+			return result, err
+		}
+
+		filter = bson.M{"$or": bson.A{
+			bson.M{"start_date": bson.M{"$gt": last.StartDate}},
+			bson.M{"start_date": last.StartDate, "_id": bson.M{"$gt": last.MongoId}},
+		}}
+		opts = options.Find().SetSort(sort).SetLimit(pageSize)
+	}
+
+	now := time.Now()
+	cursor, err := database.Collection("sprints").Find(ctx, filter, opts)
+	if err != nil {
+		return result, err
+	}
+	result.Rows, result.Bytes, err = drainCursor(ctx, cursor)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// IndexMongoSprints supports paging through the sprints of the referencing
+// model like the (start_date, id) index does in SQL.
+func IndexMongoSprints(database *mongo.Database) (err error) {
+	_, err = database.Collection("sprints").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "start_date", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	return err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// poolQueries is the number of filtered reads every combination of pool size
+// and worker count runs, split across the workers.
+const poolQueries = 256
+
+// poolSelectivity is the selectivity of the filtered read the pool sweep
+// runs, small enough that the cost of getting a connection shows.
+const poolSelectivity = 1
+
+// PoolResult is the outcome of running the pool sweep's reads with a number
+// of concurrent workers.
+type PoolResult struct {
+	// Throughput is the number of reads per second over all workers.
+	Throughput float64
+	// P99 is the 99th percentile of the read latencies, including the time
+	// spent waiting for a connection.
+	P99 time.Duration
+}
+
+// SweepPools fills every variant with the same projects and reads them
+// concurrently through clients with a connection pool of every size, using
+// every worker count, so that the drivers are compared with the same pool
+// sizes instead of their defaults. Variants without a pool show up as "-".
+// It returns the table rows and the throughput and p99 latency chart series
+// of every variant, keyed by pool size and variant name.
+func SweepPools(variants []Variant, size int, poolSizes []int, workerCounts []int) (rows [][]string, throughput map[int]map[string][]opts.LineData, latency map[int]map[string][]opts.LineData, err error) {
+	err = FillVariants(variants, GenerateProjects(size))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	throughput = make(map[int]map[string][]opts.LineData)
+	latency = make(map[int]map[string][]opts.LineData)
+	for _, poolSize := range poolSizes {
+		throughput[poolSize] = make(map[string][]opts.LineData)
+		latency[poolSize] = make(map[string][]opts.LineData)
+
+		cells := make(map[string][]string)
+		for _, variant := range variants {
+			if variant.Reconnect == nil {
+				continue
+			}
+
+			pooled, closePool, err := variant.Reconnect(poolSize)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("connecting %s with a pool of %d: %w", variant.Name, poolSize, err)
+			}
+
+			for _, workers := range workerCounts {
+				result, err := RunConcurrently(pooled.Filters["Find with filter"], ThresholdFor(poolSelectivity), workers, poolQueries)
+				if err != nil {
+					closePool()
+					return nil, nil, nil, fmt.Errorf("%s with a pool of %d and %d workers: %w", variant.Name, poolSize, workers, err)
+				}
+
+				cells[variant.Name] = append(cells[variant.Name], fmt.Sprintf("%.0f/s, p99 %s", result.Throughput, result.P99.Round(10*time.Microsecond)))
+				throughput[poolSize][variant.Name] = append(throughput[poolSize][variant.Name], opts.LineData{Value: result.Throughput})
+				latency[poolSize][variant.Name] = append(latency[poolSize][variant.Name], opts.LineData{Value: float64(result.P99.Microseconds()) / 1000})
+			}
+			closePool()
+		}
+
+		for i, workers := range workerCounts {
+			row := []string{fmt.Sprint(poolSize), fmt.Sprint(workers)}
+			for _, variant := range variants {
+				if cell, ok := cells[variant.Name]; ok {
+					row = append(row, cell[i])
+				} else {
+					row = append(row, "-")
+				}
+			}
+			rows = append(rows, row)
+		}
+		rows = append(rows, []string{})
+	}
+
+	return rows, throughput, latency, nil
+}
+
+// RunConcurrently runs the filtered read the given number of times, spread
+// over the workers. One round of reads per worker warms up the pool first
+// and is not measured.
+func RunConcurrently(filter FilterFunc, threshold int, workers int, queries int) (result PoolResult, err error) {
+	_, err = runWorkers(filter, threshold, workers, workers)
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	latencies, err := runWorkers(filter, threshold, workers, queries)
+	elapsed := time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.Throughput = float64(queries) / elapsed.Seconds()
+	result.P99 = latencies[(len(latencies)*99+99)/100-1]
+	return result, nil
+}
+
+// runWorkers returns the latency of every read. A worker stops at its first
+// error, which is returned.
+func runWorkers(filter FilterFunc, threshold int, workers int, queries int) (latencies []time.Duration, err error) {
+	jobs := make(chan int, queries)
+	for i := 0; i < queries; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	latencies = make([]time.Duration, queries)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				now := time.Now()
+				_, err := filter(threshold)
+				latencies[i] = time.Since(now)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	return latencies, <-errs
+}
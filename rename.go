@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+/* OWNER RENAME */
+
+// RenameFunc gives the user with the username a new one, wherever the model
+// stores it. It returns the number of records it changed.
+type RenameFunc func(username string, newUsername string) (duration time.Duration, changed int64, err error)
+
+// RenamedUsername is the username "Rename owner" gives a user.
+func RenamedUsername(username string) string {
+	return username + "-renamed"
+}
+
+// withRename runs a rename as the "Rename owner" operation, which renames
+// the owner of the fifth project.
+func withRename(rename RenameFunc) OperationFunc {
+	return func(projects []models.Project) (Measurement, error) {
+		username := projects[4].Owner.Username
+		duration, _, err := rename(username, RenamedUsername(username))
+		return Measurement{Duration: duration}, err
+	}
+}
+
+// SweepRename renames one owner after filling every variant with projects of
+// which every owner owns the given number, for each number of projects per
+// owner. The embedded model stores a copy of the owner in every project, so
+// it has to change all of them, while the other models change one user. It
+// returns the table rows, the chart series keyed by variant name and the
+// variants whose state differs from the first one's after a rename.
+func SweepRename(variants []Variant, size int, projectsPerOwner []int) (rows [][]string, data map[string][]opts.LineData, mismatches []Mismatch, err error) {
+	data = make(map[string][]opts.LineData)
+	for _, perOwner := range projectsPerOwner {
+		projects := GenerateProjectsWithOwners(size, perOwner)
+		err = FillVariants(variants, projects)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		username := projects[0].Owner.Username
+		row := []string{fmt.Sprint(perOwner)}
+		for _, variant := range variants {
+			if variant.Rename == nil {
+				row = append(row, "-")
+				continue
+			}
+
+			duration, changed, err := variant.Rename(username, RenamedUsername(username))
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("renaming an owner of %d projects on %s: %w", perOwner, variant.Name, err)
+			}
+
+			row = append(row, fmt.Sprintf("%s (%d changed)", duration, changed))
+			data[variant.Name] = append(data[variant.Name], opts.LineData{Value: float64(duration.Microseconds()) / 1000})
+		}
+
+		states, err := States(variants)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, mismatch := range VerifyStates(size, fmt.Sprintf("Rename owner of %d projects", perOwner), variants, states) {
+			log.Printf("%s differs from %s after renaming an owner of %d projects: %s instead of %s", mismatch.Variant, variants[0].Name, perOwner, mismatch.Actual, mismatch.Expected)
+			mismatches = append(mismatches, mismatch)
+			for i, variant := range variants {
+				if variant.Name == mismatch.Variant {
+					row[1+i] += " (mismatch)"
+				}
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, data, mismatches, nil
+}
+
+func RenamePostgresUser(conn *pgxpool.Pool, username string, newUsername string) (duration time.Duration, changed int64, err error) {
+	now := time.Now()
+	tag, err := conn.Exec(context.Background(), `UPDATE users SET username = $2 WHERE username = $1;`, username, newUsername)
+	if err != nil {
+		return time.Since(now), 0, err
+	}
+	return time.Since(now), tag.RowsAffected(), nil
+}
+
+func RenameSqliteUser(conn *sql.DB, username string, newUsername string) (duration time.Duration, changed int64, err error) {
+	now := time.Now()
+	result, err := conn.ExecContext(context.Background(), `UPDATE users SET username = ? WHERE username = ?;`, newUsername, username)
+	if err != nil {
+		return time.Since(now), 0, err
+	}
+	changed, err = result.RowsAffected()
+	return time.Since(now), changed, err
+}
+
+// RenameMongoOwner renames the copy of the owner in every embedded project
+// of theirs.
+func RenameMongoOwner(database *mongo.Database, collection string, username string, newUsername string) (duration time.Duration, changed int64, err error) {
+	now := time.Now()
+	result, err := database.Collection(collection).UpdateMany(context.Background(),
+		bson.M{"owner.username": username},
+		bson.M{"$set": bson.M{"owner.username": newUsername}})
+	if err != nil {
+		return time.Since(now), 0, err
+	}
+	return time.Since(now), result.ModifiedCount, nil
+}
+
+// RenameMongoUserWithReferencing renames the owner's document in users, which
+// the projects reference by its _id. Finding the owner by username is not
+// timed, as the other models get to the user through the username directly.
+func RenameMongoUserWithReferencing(database *mongo.Database, username string, newUsername string) (duration time.Duration, changed int64, err error) {
+	ctx := context.Background()
+	users := database.Collection("users")
+	cursor, err := users.Find(ctx, bson.M{"username": username}, options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(2))
+	if err != nil {
+		return 0, 0, err
+	}
+	var owners []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err = cursor.All(ctx, &owners)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(owners) != 1 {
+		return 0, 0, fmt.Errorf("%d users are named %q, expected one", len(owners), username)
+	}
+
+	now := time.Now()
+	result, err := users.UpdateOne(ctx,
+		bson.M{"_id": owners[0].ID},
+		bson.M{"$set": bson.M{"username": newUsername}})
+	if err != nil {
+		return time.Since(now), 0, err
+	}
+	duration = time.Since(now)
+	if result.MatchedCount != 1 {
+		return duration, 0, fmt.Errorf("user %q was gone by the time it was renamed", username)
+	}
+	return duration, result.ModifiedCount, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+/* RESULTS */
+
+// Results is what results.json holds about a run.
+type Results struct {
+	Metadata     RunMetadata         `json:"metadata"`
+	Measurements []MeasurementRecord `json:"measurements"`
+}
+
+// RunMetadata describes the run, so that results from different machines
+// and configurations can be told apart.
+type RunMetadata struct {
+	RunID   string           `json:"run_id"`
+	Targets []TargetMetadata `json:"targets"`
+}
+
+// TargetMetadata describes a target and the limits it ran with. The limits
+// are read back from Docker and the server rather than taken from the
+// targets file, so they also show the defaults of an unlimited target.
+type TargetMetadata struct {
+	Name    string          `json:"name"`
+	Kind    string          `json:"kind"`
+	Managed bool            `json:"managed"`
+	Limits  ContainerLimits `json:"limits"`
+}
+
+// MeasurementRecord is one measured operation of the size runs, with what
+// the server did while it ran and where the time went. Server is nil for
+// variants whose server is not sampled, and Split for those with a server
+// that does not say how long it took.
+type MeasurementRecord struct {
+	Size      int           `json:"size"`
+	Operation string        `json:"operation"`
+	Variant   string        `json:"variant"`
+	Duration  time.Duration `json:"duration_ns"`
+	Rows      int64         `json:"rows"`
+	Bytes     int64         `json:"bytes"`
+	Server    *ServerUsage  `json:"server,omitempty"`
+	Split     *TimeSplit    `json:"split,omitempty"`
+}
+
+// runTargets are the targets of the run in the order they were opened.
+var runTargets []TargetMetadata
+
+// RecordTarget adds the target to the metadata of the run. Limits that
+// cannot be read, for example on a server that does not report its cache
+// size, are logged and left out.
+func RecordTarget(target Target, kind string, limits func() (ContainerLimits, error)) {
+	applied, err := limits()
+	if err != nil {
+		log.Printf("cannot read the limits of %s: %v", target.Name, err)
+	}
+	runTargets = append(runTargets, TargetMetadata{
+		Name:    target.Name,
+		Kind:    kind,
+		Managed: target.Managed,
+		Limits:  applied,
+	})
+}
+
+// PrintTargets prints the limits every target ran with.
+func PrintTargets() {
+	for _, target := range runTargets {
+		fmt.Printf("%s ran with %s\n", target.Name, target.Limits)
+	}
+}
+
+// NewResults collects the results of the run.
+func NewResults(measurements []MeasurementRecord) Results {
+	return Results{
+		Metadata: RunMetadata{
+			RunID:   runID,
+			Targets: runTargets,
+		},
+		Measurements: measurements,
+	}
+}
+
+// WriteResults stores the results as indented JSON at path, redacted like
+// the charts.
+func WriteResults(path string, results Results) error {
+	return WriteRedacted(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	})
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/* SERVER SAMPLING */
+
+// While an operation of the size runs is measured, the server it runs on is
+// sampled: its container through the Docker API, and the server itself
+// through the statistics it keeps. The samples are reduced to what changed
+// during the operation, which results.json stores with the measurement.
+// The sampler's own queries show up in the server's counters too.
+
+// sampleInterval is how often the server is sampled while an operation runs,
+// on top of the samples taken right before and after it.
+const sampleInterval = 100 * time.Millisecond
+
+// ContainerSample is what Docker reports about a container at one point in
+// time.
+type ContainerSample struct {
+	// CPU is the CPU time the container has used since it started.
+	CPU time.Duration
+	// Memory is the memory the container uses, without the page cache it
+	// could give back, like docker stats shows it.
+	Memory     int64
+	BlockRead  int64
+	BlockWrite int64
+}
+
+// ServerSample is a sample of the server a variant runs on. Container is nil
+// for servers that do not run in a container of this run.
+type ServerSample struct {
+	Time      time.Time
+	Container *ContainerSample
+	// Counters are the server's cumulative statistics, keyed by the view or
+	// section and the name they have there.
+	Counters map[string]float64
+	// Execution is the time the server has spent executing statements, or
+	// nil if it does not say.
+	Execution *time.Duration
+}
+
+// SampleFunc samples the server a variant runs on.
+type SampleFunc func(ctx context.Context) (ServerSample, error)
+
+// ContainerUsage is what the container of a server used during an operation.
+type ContainerUsage struct {
+	CPU time.Duration `json:"cpu_ns"`
+	// Cores is the CPU time divided by the time the operation took, which is
+	// the number of cores it kept busy on average.
+	Cores      float64 `json:"cores"`
+	PeakMemory int64   `json:"peak_memory"`
+	BlockRead  int64   `json:"block_read"`
+	BlockWrite int64   `json:"block_write"`
+}
+
+// ServerUsage aggregates the samples taken during an operation.
+type ServerUsage struct {
+	Samples   int             `json:"samples"`
+	Container *ContainerUsage `json:"container,omitempty"`
+	// Counters holds by how much every counter of the server grew.
+	Counters map[string]float64 `json:"counters,omitempty"`
+	// Execution is the time the server spent executing the operation's
+	// statements, or nil if it does not say.
+	Execution *time.Duration `json:"execution_ns,omitempty"`
+}
+
+// SampleOperation runs the operation while sampling the server of the
+// variant and returns the measurement together with what the server did
+// meanwhile and the CPU time the benchmark's process spent on it. Variants
+// without a sampler return no usage, and neither do variants whose sampling
+// fails, which is logged. Variants with a profiler have it on only for the
+// operation. The CPU time covers the whole process, so the cost
+// of the samples polled during the operation, estimated by that of the
+// first, is taken off it.
+func SampleOperation(variant Variant, run func() (Measurement, error)) (result Measurement, usage *ServerUsage, client time.Duration, err error) {
+	timed := func() (Measurement, time.Duration, error) {
+		start := clientCPU()
+		result, err := run()
+		return result, clientCPU() - start, err
+	}
+
+	if variant.Sample == nil {
+		result, client, err = timed()
+		return result, nil, client, err
+	}
+
+	if variant.Profile != nil {
+		stopProfiling, err := variant.Profile()
+		if err != nil {
+			log.Printf("cannot profile the server of %s: %v", variant.Name, err)
+		} else {
+			defer func() {
+				err := stopProfiling()
+				if err != nil {
+					log.Printf("cannot stop profiling the server of %s: %v", variant.Name, err)
+				}
+			}()
+		}
+	}
+
+	ctx := context.Background()
+	start := clientCPU()
+	before, err := variant.Sample(ctx)
+	sampleCost := clientCPU() - start
+	if err != nil {
+		log.Printf("cannot sample the server of %s: %v", variant.Name, err)
+		result, client, err = timed()
+		return result, nil, client, err
+	}
+
+	stop := make(chan struct{})
+	polled := make(chan []ServerSample)
+	polls := 0
+	go func() {
+		var samples []ServerSample
+		ticker := time.NewTicker(sampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				polled <- samples
+				return
+			case <-ticker.C:
+				polls++
+				sample, err := variant.Sample(ctx)
+				if err == nil {
+					samples = append(samples, sample)
+				}
+			}
+		}
+	}()
+
+	result, client, err = timed()
+	close(stop)
+	samples := <-polled
+	client = max(client-time.Duration(polls)*sampleCost, 0)
+	if err != nil {
+		return result, nil, client, err
+	}
+
+	after, err := variant.Sample(ctx)
+	if err != nil {
+		log.Printf("cannot sample the server of %s: %v", variant.Name, err)
+		return result, nil, client, nil
+	}
+
+	samples = append([]ServerSample{before}, samples...)
+	return result, aggregateSamples(append(samples, after)), client, nil
+}
+
+// aggregateSamples reduces the samples of an operation, the first taken
+// before and the last after it, to the usage of the server in between.
+func aggregateSamples(samples []ServerSample) *ServerUsage {
+	first, last := samples[0], samples[len(samples)-1]
+	usage := &ServerUsage{Samples: len(samples)}
+
+	if first.Container != nil && last.Container != nil {
+		container := &ContainerUsage{
+			CPU:        last.Container.CPU - first.Container.CPU,
+			BlockRead:  last.Container.BlockRead - first.Container.BlockRead,
+			BlockWrite: last.Container.BlockWrite - first.Container.BlockWrite,
+		}
+		if elapsed := last.Time.Sub(first.Time); elapsed > 0 {
+			container.Cores = container.CPU.Seconds() / elapsed.Seconds()
+		}
+		for _, sample := range samples {
+			if sample.Container != nil {
+				container.PeakMemory = max(container.PeakMemory, sample.Container.Memory)
+			}
+		}
+		usage.Container = container
+	}
+
+	for name, value := range last.Counters {
+		if start, ok := first.Counters[name]; ok {
+			if usage.Counters == nil {
+				usage.Counters = make(map[string]float64)
+			}
+			usage.Counters[name] = value - start
+		}
+	}
+
+	if first.Execution != nil && last.Execution != nil {
+		execution := *last.Execution - *first.Execution
+		usage.Execution = &execution
+	}
+	return usage
+}
+
+// dockerClient is the client the containers are sampled through.
+var dockerClient = sync.OnceValues(func() (*testcontainers.DockerClient, error) {
+	return testcontainers.NewDockerClientWithOpts(context.Background())
+})
+
+// sampleContainer asks Docker for the current usage of the container. It
+// returns nil for targets without a container.
+func sampleContainer(ctx context.Context, c testcontainers.Container) (*ContainerSample, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	client, err := dockerClient()
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.ContainerStatsOneShot(ctx, c.GetContainerID())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var stats struct {
+		CPUStats struct {
+			CPUUsage struct {
+				TotalUsage int64 `json:"total_usage"`
+			} `json:"cpu_usage"`
+		} `json:"cpu_stats"`
+		MemoryStats struct {
+			Usage int64            `json:"usage"`
+			Stats map[string]int64 `json:"stats"`
+		} `json:"memory_stats"`
+		BlkioStats struct {
+			IoServiceBytesRecursive []struct {
+				Op    string `json:"op"`
+				Value int64  `json:"value"`
+			} `json:"io_service_bytes_recursive"`
+		} `json:"blkio_stats"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&stats)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := &ContainerSample{
+		CPU:    time.Duration(stats.CPUStats.CPUUsage.TotalUsage),
+		Memory: stats.MemoryStats.Usage,
+	}
+	// cgroup v2 calls it inactive_file, v1 total_inactive_file.
+	if inactive, ok := stats.MemoryStats.Stats["inactive_file"]; ok {
+		sample.Memory -= inactive
+	} else if inactive, ok := stats.MemoryStats.Stats["total_inactive_file"]; ok {
+		sample.Memory -= inactive
+	}
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			sample.BlockRead += entry.Value
+		case "write":
+			sample.BlockWrite += entry.Value
+		}
+	}
+	return sample, nil
+}
+
+/* POSTGRES */
+
+// samplerComment marks the sampler's queries, which are then left out of
+// the sums over pg_stat_statements.
+const samplerComment = "/* sampler */ "
+
+// SamplePostgres samples the database of the connector through a pool of its
+// own. It reads pg_stat_database and, where the server preloads it,
+// pg_stat_statements, which it creates in the database if needed and which
+// also tells the time spent executing statements, like EXPLAIN ANALYZE does
+// without running them again. Postgres
+// flushes the pg_stat_database counters of a busy connection at most once a
+// second, so those of short operations can show up in a later one, while
+// pg_stat_statements counts every statement as it finishes.
+func SamplePostgres(name string, connector *PostgresConnector) (SampleFunc, error) {
+	ctx := context.Background()
+	pool, err := connector.Open("", 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := true
+	_, err = pool.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS pg_stat_statements;`)
+	if err == nil {
+		err = pool.QueryRow(ctx, `SELECT 1 FROM pg_stat_statements LIMIT 1;`).Scan(new(int))
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = nil
+		}
+	}
+	if err != nil {
+		log.Printf("sampling %s without pg_stat_statements: %v", name, err)
+		statements = false
+	}
+
+	sample := func(ctx context.Context) (sample ServerSample, err error) {
+		sample.Time = time.Now()
+		sample.Container, err = sampleContainer(ctx, connector.container)
+		if err != nil {
+			return sample, err
+		}
+
+		sample.Counters = make(map[string]float64)
+		err = postgresCounters(ctx, pool, "pg_stat_database", `
+			SELECT xact_commit::FLOAT8, xact_rollback::FLOAT8, blks_read::FLOAT8, blks_hit::FLOAT8,
+				tup_returned::FLOAT8, tup_fetched::FLOAT8, tup_inserted::FLOAT8, tup_updated::FLOAT8, tup_deleted::FLOAT8,
+				temp_bytes::FLOAT8
+			FROM pg_stat_database WHERE datname = current_database();`, sample.Counters)
+		if err != nil {
+			return sample, err
+		}
+		if statements {
+			err = postgresCounters(ctx, pool, "pg_stat_statements", `
+				SELECT COALESCE(SUM(calls), 0)::FLOAT8 AS calls, COALESCE(SUM(total_exec_time), 0)::FLOAT8 AS total_exec_time_ms,
+					COALESCE(SUM(rows), 0)::FLOAT8 AS rows,
+					COALESCE(SUM(shared_blks_hit), 0)::FLOAT8 AS shared_blks_hit, COALESCE(SUM(shared_blks_read), 0)::FLOAT8 AS shared_blks_read,
+					COALESCE(SUM(shared_blks_dirtied), 0)::FLOAT8 AS shared_blks_dirtied, COALESCE(SUM(shared_blks_written), 0)::FLOAT8 AS shared_blks_written,
+					COALESCE(SUM(temp_blks_written), 0)::FLOAT8 AS temp_blks_written, COALESCE(SUM(wal_bytes), 0)::FLOAT8 AS wal_bytes
+				FROM pg_stat_statements
+				WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database()) AND query NOT LIKE '%/* sampler */%';`, sample.Counters)
+			execution := time.Duration(sample.Counters["pg_stat_statements.total_exec_time_ms"] * float64(time.Millisecond))
+			sample.Execution = &execution
+		}
+		return sample, err
+	}
+
+	_, err = sample(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// postgresCounters runs a query returning a single row of FLOAT8 columns and
+// adds every column to the counters under the view's name.
+func postgresCounters(ctx context.Context, pool *pgxpool.Pool, view string, query string, counters map[string]float64) error {
+	rows, err := pool.Query(ctx, samplerComment+query)
+	if err != nil {
+		return err
+	}
+	values, err := pgx.CollectExactlyOneRow(rows, pgx.RowToMap)
+	if err != nil {
+		return err
+	}
+
+	for column, value := range values {
+		number, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s.%s is a %T, not a number", view, column, value)
+		}
+		counters[view+"."+column] = number
+	}
+	return nil
+}
+
+/* MONGODB */
+
+// mongoCounters are the serverStatus counters the Mongo sampler reads.
+var mongoCounters = [][]string{
+	{"opcounters", "insert"},
+	{"opcounters", "query"},
+	{"opcounters", "update"},
+	{"opcounters", "delete"},
+	{"opcounters", "getmore"},
+	{"opcounters", "command"},
+	{"metrics", "document", "returned"},
+	{"metrics", "document", "inserted"},
+	{"metrics", "document", "updated"},
+	{"metrics", "document", "deleted"},
+	{"metrics", "queryExecutor", "scanned"},
+	{"metrics", "queryExecutor", "scannedObjects"},
+	{"wiredTiger", "cache", "bytes read into cache"},
+	{"wiredTiger", "cache", "bytes written from cache"},
+	{"wiredTiger", "cache", "pages read into cache"},
+	{"wiredTiger", "cache", "pages written from cache"},
+}
+
+// SampleMongo samples the server of the connector through serverStatus,
+// which counts the operations of every database on it. The time spent
+// executing them comes from the profiler of each variant's database, see
+// ProfileMongo. It fails if the server does not allow serverStatus, which
+// Atlas restricts on shared tiers.
+func SampleMongo(connector *MongoConnector, client *mongo.Client) (SampleFunc, error) {
+	admin := client.Database("admin")
+	sample := func(ctx context.Context) (sample ServerSample, err error) {
+		sample.Time = time.Now()
+		sample.Container, err = sampleContainer(ctx, connector.container)
+		if err != nil {
+			return sample, err
+		}
+
+		status, err := admin.RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).Raw()
+		if err != nil {
+			return sample, err
+		}
+		sample.Counters = make(map[string]float64)
+		for _, path := range mongoCounters {
+			value, err := status.LookupErr(path...)
+			if err != nil {
+				continue
+			}
+			if number, ok := value.AsInt64OK(); ok {
+				sample.Counters[strings.Join(path, ".")] = float64(number)
+			}
+		}
+		return sample, nil
+	}
+
+	_, err := sample(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// profileSize is the size of the capped collection the profiler writes to,
+// large enough to keep every entry of the largest size run, where the
+// default of 1MB drops entries between two samples.
+const profileSize = 256 << 20
+
+// ProfileFunc turns on the profiling of a variant's server and returns the
+// function that turns it off again.
+type ProfileFunc func() (stop func() error, err error)
+
+// ProfileMongo adds the time the server spent on the operations in a
+// variant's database to the samples, so neither other databases nor the
+// sampler's own commands count. The profiler that records it writes an entry
+// for every operation, which the operations pay for, so it only runs while
+// the returned profile function has it on. Every time it is turned on, the
+// profile starts out empty and the samples sum the millis of all its
+// entries, which the profiler records in whole milliseconds. The returned
+// stop function turns the profiler off if it is still on.
+func ProfileMongo(sample SampleFunc, database *mongo.Database) (profiled SampleFunc, profile ProfileFunc, stop func() error, err error) {
+	stop = func() error {
+		return database.RunCommand(context.Background(), bson.D{{Key: "profile", Value: 0}}).Err()
+	}
+	err = stop()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	profile = func() (func() error, error) {
+		ctx := context.Background()
+		err := database.Collection("system.profile").Drop(ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = database.CreateCollection(ctx, "system.profile", options.CreateCollection().SetCapped(true).SetSizeInBytes(profileSize))
+		if err != nil {
+			return nil, err
+		}
+		err = database.RunCommand(ctx, bson.D{{Key: "profile", Value: 2}}).Err()
+		if err != nil {
+			return nil, err
+		}
+		return stop, nil
+	}
+
+	profiled = func(ctx context.Context) (ServerSample, error) {
+		result, err := sample(ctx)
+		if err != nil {
+			return result, err
+		}
+
+		cursor, err := database.Collection("system.profile").Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"ns": bson.M{"$ne": database.Name() + ".system.profile"}}}},
+			{{Key: "$group", Value: bson.M{"_id": nil, "millis": bson.M{"$sum": "$millis"}}}},
+		})
+		if err != nil {
+			return result, err
+		}
+
+		var entries []struct {
+			Millis int64 `bson:"millis"`
+		}
+		err = cursor.All(ctx, &entries)
+		if err != nil {
+			return result, err
+		}
+
+		var execution time.Duration
+		for _, entry := range entries {
+			execution += time.Duration(entry.Millis) * time.Millisecond
+		}
+		result.Execution = &execution
+		return result, nil
+	}
+	return profiled, profile, stop, nil
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+/* SEARCH */
+
+// Search queries match project and sprint names case-insensitively and
+// return every project whose name or one of whose sprint names matches.
+const (
+	// PrefixSearch matches names starting with the term.
+	PrefixSearch = "Prefix"
+	// InfixSearch matches names containing the term.
+	InfixSearch = "Infix"
+	// WordSearch matches names that are the term. Generated names are single
+	// words, so this is the query full-text indexes are built for.
+	WordSearch = "Word"
+)
+
+var searchKinds = []string{PrefixSearch, InfixSearch, WordSearch}
+
+// SearchFunc runs a search query of the given kind for the term.
+type SearchFunc func(kind string, term string) (Measurement, error)
+
+// SearchMethod is one way a database can search names, for example with a
+// trigram index.
+type SearchMethod struct {
+	Name string
+	// Kinds lists the queries the method can answer.
+	Kinds []string
+	// Prepare creates what the method needs, such as an index, and Cleanup
+	// removes it again so that the next method is measured without it.
+	// Neither is measured and both may be nil.
+	Prepare func() error
+	Cleanup func() error
+	Search  SearchFunc
+}
+
+// SearchTerms picks a term of every kind from the projects, so that every
+// query finds at least one project.
+func SearchTerms(projects []models.Project) map[string]string {
+	project := projects[len(projects)/2]
+	return map[string]string{
+		PrefixSearch: project.Name[:3],
+		InfixSearch:  project.Name[3:6],
+		WordSearch:   project.Sprints[0].Name,
+	}
+}
+
+// BenchmarkSearch fills every variant with the same projects and runs each
+// kind of query with each search method of the variant. It returns the table
+// rows and the queries on which variants returned different numbers of
+// projects.
+func BenchmarkSearch(variants []Variant, size int) (rows [][]string, mismatches []CardinalityMismatch, err error) {
+	projects := GenerateProjects(size)
+	err = FillVariants(variants, projects)
+	if err != nil {
+		return nil, nil, err
+	}
+	terms := SearchTerms(projects)
+
+	// results holds the measurements by kind, method and variant.
+	results := make(map[string]map[string]map[string]Measurement)
+	for _, kind := range searchKinds {
+		results[kind] = make(map[string]map[string]Measurement)
+	}
+	methods := make([]string, 0)
+
+	for _, variant := range variants {
+		for _, method := range variant.Searches {
+			if results[searchKinds[0]][method.Name] == nil {
+				methods = append(methods, method.Name)
+				for _, kind := range searchKinds {
+					results[kind][method.Name] = make(map[string]Measurement)
+				}
+			}
+
+			if method.Prepare != nil {
+				err = method.Prepare()
+				if err != nil {
+					log.Printf("skipping %s on %s: %v", method.Name, variant.Name, err)
+					continue
+				}
+			}
+
+			for _, kind := range method.Kinds {
+				result, err := method.Search(kind, terms[kind])
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s search with %s on %s: %w", kind, method.Name, variant.Name, err)
+				}
+				results[kind][method.Name][variant.Name] = result
+			}
+
+			if method.Cleanup != nil {
+				err = method.Cleanup()
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	for _, kind := range searchKinds {
+		for _, method := range methods {
+			measurements := results[kind][method]
+			if len(measurements) == 0 {
+				continue
+			}
+
+			row := []string{kind, method}
+			for _, variant := range variants {
+				result, ok := measurements[variant.Name]
+				if !ok {
+					row = append(row, "-")
+					continue
+				}
+				row = append(row, fmt.Sprintf("%s (%d)", result.Duration, result.Rows))
+			}
+
+			name := fmt.Sprintf("%s search with %s for %q", kind, method, terms[kind])
+			for _, mismatch := range VerifyCardinality(size, name, variants, measurements) {
+				log.Printf("%s returned %d projects for %s, %s returned %d", mismatch.Variant, mismatch.Actual, mismatch.Operation, mismatch.Reference, mismatch.Expected)
+				mismatches = append(mismatches, mismatch)
+				for i, variant := range variants {
+					if variant.Name == mismatch.Variant {
+						row[2+i] += " (rows differ)"
+					}
+				}
+			}
+
+			rows = append(rows, row)
+		}
+		rows = append(rows, []string{})
+	}
+
+	return rows, mismatches, nil
+}
+
+// likePattern turns a term into a LIKE pattern for the kind of query.
+func likePattern(kind string, term string) string {
+	switch kind {
+	case PrefixSearch:
+		return term + "%"
+	case InfixSearch:
+		return "%" + term + "%"
+	default:
+		return term
+	}
+}
+
+// searchRegex turns a term into a regular expression for the kind of query.
+func searchRegex(kind string, term string) string {
+	term = regexp.QuoteMeta(term)
+	switch kind {
+	case PrefixSearch:
+		return "^" + term
+	case InfixSearch:
+		return term
+	default:
+		return "^" + term + "$"
+	}
+}
+
+/* POSTGRES */
+
+func PostgresSearches(conn *pgxpool.Pool) []SearchMethod {
+	exec := func(queries ...string) func() error {
+		return func() error {
+			for _, query := range queries {
+				_, err := conn.Exec(context.Background(), query)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	return []SearchMethod{
+		{
+			Name:  "ILIKE",
+			Kinds: searchKinds,
+			Search: func(kind string, term string) (Measurement, error) {
+				return SearchPostgres(conn, `%[1]s ILIKE $1`, likePattern(kind, term))
+			},
+		},
+		{
+			// The extension goes into public, which is shared by the
+			// schemas of all variants.
+			Name:  "pg_trgm",
+			Kinds: searchKinds,
+			Prepare: exec(
+				`CREATE EXTENSION IF NOT EXISTS pg_trgm SCHEMA public`,
+				`CREATE INDEX projects_name_trgm ON projects USING gin (name public.gin_trgm_ops)`,
+				`CREATE INDEX sprints_name_trgm ON sprints USING gin (name public.gin_trgm_ops)`,
+			),
+			Cleanup: exec(`DROP INDEX projects_name_trgm`, `DROP INDEX sprints_name_trgm`),
+			Search: func(kind string, term string) (Measurement, error) {
+				return SearchPostgres(conn, `%[1]s ILIKE $1`, likePattern(kind, term))
+			},
+		},
+		{
+			Name:  "tsvector",
+			Kinds: []string{PrefixSearch, WordSearch},
+			Prepare: exec(
+				`CREATE INDEX projects_name_tsv ON projects USING gin (to_tsvector('simple', name))`,
+				`CREATE INDEX sprints_name_tsv ON sprints USING gin (to_tsvector('simple', name))`,
+			),
+			Cleanup: exec(`DROP INDEX projects_name_tsv`, `DROP INDEX sprints_name_tsv`),
+			Search: func(kind string, term string) (Measurement, error) {
+				if kind == PrefixSearch {
+					term += ":*"
+				}
+				return SearchPostgres(conn, `to_tsvector('simple', %[1]s) @@ to_tsquery('simple', $1)`, term)
+			},
+		},
+	}
+}
+
+// SearchPostgres returns the projects for which the condition holds on the
+// project name or on the name of one of their sprints. The condition refers
+// to the name as %[1]s.
+func SearchPostgres(conn *pgxpool.Pool, condition string, argument string) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = queryPostgres(conn, `SELECT * FROM projects WHERE `+fmt.Sprintf(condition, "projects.name")+` UNION SELECT projects.* FROM projects INNER JOIN sprints ON sprints.project_id = projects.id WHERE `+fmt.Sprintf(condition, "sprints.name")+`;`, argument)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+/* SQLITE */
+
+func SqliteSearches(conn *sql.DB) []SearchMethod {
+	return []SearchMethod{
+		{
+			// LIKE ignores the case of ASCII letters in SQLite.
+			Name:  "LIKE",
+			Kinds: searchKinds,
+			Search: func(kind string, term string) (Measurement, error) {
+				return SearchSqlite(conn, likePattern(kind, term))
+			},
+		},
+	}
+}
+
+func SearchSqlite(conn *sql.DB, pattern string) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = querySqlite(conn, `SELECT * FROM projects WHERE name LIKE ?1 UNION SELECT projects.* FROM projects INNER JOIN sprints ON sprints.project_id = projects.id WHERE sprints.name LIKE ?1;`, pattern)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+/* MONGODB */
+
+// caseInsensitive compares strings ignoring case, like ILIKE does.
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// mongoIndexes returns a Prepare and a Cleanup function that create and drop
+// indexes on a collection.
+func mongoIndexes(collection *mongo.Collection, models ...mongo.IndexModel) (prepare func() error, cleanup func() error) {
+	var names []string
+	prepare = func() error {
+		var err error
+		names, err = collection.Indexes().CreateMany(context.Background(), models)
+		return err
+	}
+	cleanup = func() error {
+		for _, name := range names {
+			_, err := collection.Indexes().DropOne(context.Background(), name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return prepare, cleanup
+}
+
+func MongoSearches(database *mongo.Database, collection string) []SearchMethod {
+	projects := database.Collection(collection)
+	prepareCollation, cleanupCollation := mongoIndexes(projects,
+		mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetCollation(caseInsensitive)},
+		mongo.IndexModel{Keys: bson.D{{Key: "sprints.name", Value: 1}}, Options: options.Index().SetCollation(caseInsensitive)},
+	)
+	prepareText, cleanupText := mongoIndexes(projects,
+		mongo.IndexModel{Keys: bson.D{{Key: "name", Value: "text"}, {Key: "sprints.name", Value: "text"}}, Options: options.Index().SetDefaultLanguage("none")},
+	)
+
+	return []SearchMethod{
+		{
+			Name:  "$regex",
+			Kinds: searchKinds,
+			Search: func(kind string, term string) (Measurement, error) {
+				regex := mongoRegex(kind, term)
+				return SearchMongo(database, collection, bson.M{"$or": bson.A{bson.M{"name": regex}, bson.M{"sprints.name": regex}}}, nil)
+			},
+		},
+		{
+			Name:    "collation index",
+			Kinds:   []string{WordSearch},
+			Prepare: prepareCollation,
+			Cleanup: cleanupCollation,
+			Search: func(kind string, term string) (Measurement, error) {
+				return SearchMongo(database, collection, bson.M{"$or": bson.A{bson.M{"name": term}, bson.M{"sprints.name": term}}}, caseInsensitive)
+			},
+		},
+		{
+			Name:    "$text",
+			Kinds:   []string{WordSearch},
+			Prepare: prepareText,
+			Cleanup: cleanupText,
+			Search: func(kind string, term string) (Measurement, error) {
+				return SearchMongo(database, collection, bson.M{"$text": bson.M{"$search": term}}, nil)
+			},
+		},
+	}
+}
+
+func SearchMongo(database *mongo.Database, collection string, filter bson.M, collation *options.Collation) (result Measurement, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	cursor, err := database.Collection(collection).Find(ctx, filter, options.Find().SetCollation(collation))
+	if err != nil {
+		return result, err
+	}
+	result.Rows, result.Bytes, err = drainCursor(ctx, cursor)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func MongoSearchesWithReferencing(database *mongo.Database) []SearchMethod {
+	prepareProjectCollation, cleanupProjectCollation := mongoIndexes(database.Collection("projects"),
+		mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetCollation(caseInsensitive)},
+	)
+	prepareSprintCollation, cleanupSprintCollation := mongoIndexes(database.Collection("sprints"),
+		mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetCollation(caseInsensitive)},
+	)
+	prepareProjectText, cleanupProjectText := mongoIndexes(database.Collection("projects"),
+		mongo.IndexModel{Keys: bson.D{{Key: "name", Value: "text"}}, Options: options.Index().SetDefaultLanguage("none")},
+	)
+	prepareSprintText, cleanupSprintText := mongoIndexes(database.Collection("sprints"),
+		mongo.IndexModel{Keys: bson.D{{Key: "name", Value: "text"}}, Options: options.Index().SetDefaultLanguage("none")},
+	)
+
+	return []SearchMethod{
+		{
+			Name:  "$regex",
+			Kinds: searchKinds,
+			Search: func(kind string, term string) (Measurement, error) {
+				regex := mongoRegex(kind, term)
+				return SearchMongoWithReferencing(database, bson.M{"name": regex}, nil)
+			},
+		},
+		{
+			Name:    "collation index",
+			Kinds:   []string{WordSearch},
+			Prepare: both(prepareProjectCollation, prepareSprintCollation),
+			Cleanup: both(cleanupProjectCollation, cleanupSprintCollation),
+			Search: func(kind string, term string) (Measurement, error) {
+				return SearchMongoWithReferencing(database, bson.M{"name": term}, caseInsensitive)
+			},
+		},
+		{
+			Name:    "$text",
+			Kinds:   []string{WordSearch},
+			Prepare: both(prepareProjectText, prepareSprintText),
+			Cleanup: both(cleanupProjectText, cleanupSprintText),
+			Search: func(kind string, term string) (Measurement, error) {
+				return SearchMongoWithReferencing(database, bson.M{"$text": bson.M{"$search": term}}, nil)
+			},
+		},
+	}
+}
+
+// SearchMongoWithReferencing looks up the projects of the sprints matching
+// the filter first and then returns them together with the projects that
+// match the filter themselves.
+func SearchMongoWithReferencing(database *mongo.Database, filter bson.M, collation *options.Collation) (result Measurement, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	projectIds, err := database.Collection("sprints").Distinct(ctx, "project", filter, options.Distinct().SetCollation(collation))
+	if err != nil {
+		return result, err
+	}
+
+	cursor, err := database.Collection("projects").Find(ctx, bson.M{"$or": bson.A{filter, bson.M{"_id": bson.M{"$in": projectIds}}}}, options.Find().SetCollation(collation))
+	if err != nil {
+		return result, err
+	}
+	result.Rows, result.Bytes, err = drainCursor(ctx, cursor)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// mongoRegex matches the term case-insensitively, like ILIKE does.
+func mongoRegex(kind string, term string) bson.M {
+	return bson.M{"$regex": searchRegex(kind, term), "$options": "i"}
+}
+
+// both runs two functions, stopping at the first error.
+func both(first func() error, second func() error) func() error {
+	return func() error {
+		err := first()
+		if err != nil {
+			return err
+		}
+		return second()
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// SweepSelectivity fills every variant with the same projects and runs each
+// filtered read once per selectivity, so that indexed and unindexed variants
+// can be compared from filters that select almost nothing to filters that
+// select almost everything. It returns the table rows, the chart series of
+// every read keyed by operation and variant name, and the reads on which
+// variants returned different numbers of records.
+func SweepSelectivity(variants []Variant, size int, selectivities []int) (rows [][]string, data map[string]map[string][]opts.LineData, mismatches []CardinalityMismatch, err error) {
+	err = FillVariants(variants, GenerateProjects(size))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	data = make(map[string]map[string][]opts.LineData)
+	for _, operation := range operations {
+		for _, selectivity := range selectivities {
+			row := []string{fmt.Sprintf("%d%%", selectivity), operation.Name}
+			results := make(map[string]Measurement)
+
+			for _, variant := range variants {
+				filter, ok := variant.Filters[operation.Name]
+				if !ok {
+					row = append(row, "-")
+					continue
+				}
+
+				result, err := filter(ThresholdFor(selectivity))
+				if err != nil {
+					return nil, nil, nil, err
+				}
+
+				results[variant.Name] = result
+				row = append(row, fmt.Sprintf("%s (%d)", result.Duration, result.Rows))
+
+				if data[operation.Name] == nil {
+					data[operation.Name] = make(map[string][]opts.LineData)
+				}
+				data[operation.Name][variant.Name] = append(data[operation.Name][variant.Name], opts.LineData{Value: float64(result.Duration.Microseconds()) / 1000})
+			}
+
+			if len(results) == 0 {
+				break
+			}
+
+			name := fmt.Sprintf("%s at %d%% selectivity", operation.Name, selectivity)
+			for _, mismatch := range VerifyCardinality(size, name, variants, results) {
+				log.Printf("%s returned %d records for %s, %s returned %d", mismatch.Variant, mismatch.Actual, mismatch.Operation, mismatch.Reference, mismatch.Expected)
+				mismatches = append(mismatches, mismatch)
+				for i, variant := range variants {
+					if variant.Name == mismatch.Variant {
+						row[2+i] += " (rows differ)"
+					}
+				}
+			}
+
+			rows = append(rows, row)
+		}
+
+		if _, ok := data[operation.Name]; ok {
+			rows = append(rows, []string{})
+		}
+	}
+
+	return rows, data, mismatches, nil
+}
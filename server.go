@@ -5,25 +5,39 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/fabiansefranek/dbi-perf-tests/db"
 	"github.com/fabiansefranek/dbi-perf-tests/handlers"
 	"github.com/fabiansefranek/dbi-perf-tests/views"
 )
 
 func StartServer() {
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	fmt.Println("Listening on :3000")
+	http.ListenAndServe(":3000", NewServer())
+}
+
+// NewServer registers the routes of the web UI on a mux of its own.
+func NewServer() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 
 	/* POSTGRES */
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Without containers only the SQLite backend is available.
+		if db.PostgresConn == nil {
+			http.Redirect(w, r, "/sqlite", http.StatusTemporaryRedirect)
+			return
+		}
+
 		nameSearch := r.URL.Query().Get("name")
 
 		views.PostgresIndex(nameSearch).Render(r.Context(), w)
 	})
 
-	http.Handle("POST /postgres/users", http.HandlerFunc(handlers.AddUser))
-	http.Handle("POST /postgres/users/delete", http.HandlerFunc(handlers.DeleteUser))
-	http.Handle("POST /postgres/users/update", http.HandlerFunc(handlers.UpdateUser))
-	http.HandleFunc("GET /postgres/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /postgres/users", http.HandlerFunc(handlers.AddUser))
+	mux.Handle("POST /postgres/users/delete", http.HandlerFunc(handlers.DeleteUser))
+	mux.Handle("POST /postgres/users/update", http.HandlerFunc(handlers.UpdateUser))
+	mux.HandleFunc("GET /postgres/users/{id}", func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 		idInt, err := strconv.Atoi(id)
 		if err != nil {
@@ -33,10 +47,10 @@ func StartServer() {
 		views.User("postgres", idInt).Render(r.Context(), w)
 	})
 
-	http.Handle("POST /postgres/sprints", http.HandlerFunc(handlers.AddSprint))
-	http.Handle("POST /postgres/sprints/delete", http.HandlerFunc(handlers.DeleteSprint))
-	http.Handle("POST /postgres/sprints/update", http.HandlerFunc(handlers.UpdateSprint))
-	http.HandleFunc("GET /postgres/sprints/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /postgres/sprints", http.HandlerFunc(handlers.AddSprint))
+	mux.Handle("POST /postgres/sprints/delete", http.HandlerFunc(handlers.DeleteSprint))
+	mux.Handle("POST /postgres/sprints/update", http.HandlerFunc(handlers.UpdateSprint))
+	mux.HandleFunc("GET /postgres/sprints/{id}", func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 		idInt, err := strconv.Atoi(id)
 		if err != nil {
@@ -46,10 +60,10 @@ func StartServer() {
 		views.Sprint("postgres", idInt).Render(r.Context(), w)
 	})
 
-	http.Handle("POST /postgres/projects", http.HandlerFunc(handlers.AddProject))
-	http.Handle("POST /postgres/projects/delete", http.HandlerFunc(handlers.DeleteProject))
-	http.Handle("POST /postgres/projects/update", http.HandlerFunc(handlers.UpdateProject))
-	http.HandleFunc("GET /postgres/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /postgres/projects", http.HandlerFunc(handlers.AddProject))
+	mux.Handle("POST /postgres/projects/delete", http.HandlerFunc(handlers.DeleteProject))
+	mux.Handle("POST /postgres/projects/update", http.HandlerFunc(handlers.UpdateProject))
+	mux.HandleFunc("GET /postgres/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 		idInt, err := strconv.Atoi(id)
 		if err != nil {
@@ -61,40 +75,86 @@ func StartServer() {
 
 	/* MONGODB */
 
-	http.HandleFunc("/mongo", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/mongo", func(w http.ResponseWriter, r *http.Request) {
 		nameSearch := r.URL.Query().Get("name")
 
 		views.MongoIndex(nameSearch).Render(r.Context(), w)
 	})
 
-	http.Handle("POST /mongo/users", http.HandlerFunc(handlers.AddMongoUser))
-	http.Handle("POST /mongo/users/delete", http.HandlerFunc(handlers.DeleteMongoUser))
-	http.Handle("POST /mongo/users/update", http.HandlerFunc(handlers.UpdateMongoUser))
-	http.HandleFunc("GET /mongo/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /mongo/users", http.HandlerFunc(handlers.AddMongoUser))
+	mux.Handle("POST /mongo/users/delete", http.HandlerFunc(handlers.DeleteMongoUser))
+	mux.Handle("POST /mongo/users/update", http.HandlerFunc(handlers.UpdateMongoUser))
+	mux.HandleFunc("GET /mongo/users/{id}", func(w http.ResponseWriter, r *http.Request) {
 		oid := r.PathValue("id")
 
 		views.MongoUser("mongo", oid).Render(r.Context(), w)
 	})
 
-	http.Handle("POST /mongo/sprints", http.HandlerFunc(handlers.AddMongoSprint))
-	http.Handle("POST /mongo/sprints/delete", http.HandlerFunc(handlers.DeleteMongoSprint))
-	http.Handle("POST /mongo/sprints/update", http.HandlerFunc(handlers.UpdateMongoSprint))
-	http.HandleFunc("GET /mongo/sprints/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /mongo/sprints", http.HandlerFunc(handlers.AddMongoSprint))
+	mux.Handle("POST /mongo/sprints/delete", http.HandlerFunc(handlers.DeleteMongoSprint))
+	mux.Handle("POST /mongo/sprints/update", http.HandlerFunc(handlers.UpdateMongoSprint))
+	mux.HandleFunc("GET /mongo/sprints/{id}", func(w http.ResponseWriter, r *http.Request) {
 		oid := r.PathValue("id")
 
 		views.MongoSprint("mongo", oid).Render(r.Context(), w)
 	})
 
-	http.Handle("POST /mongo/projects", http.HandlerFunc(handlers.AddMongoProject))
-	http.Handle("POST /mongo/projects/delete", http.HandlerFunc(handlers.DeleteMongoProject))
-	http.Handle("POST /mongo/projects/update", http.HandlerFunc(handlers.UpdateMongoProject))
-	http.HandleFunc("GET /mongo/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /mongo/projects", http.HandlerFunc(handlers.AddMongoProject))
+	mux.Handle("POST /mongo/projects/delete", http.HandlerFunc(handlers.DeleteMongoProject))
+	mux.Handle("POST /mongo/projects/update", http.HandlerFunc(handlers.UpdateMongoProject))
+	mux.HandleFunc("GET /mongo/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
 		oid := r.PathValue("id")
 
 		views.MongoProject("mongo", oid).Render(r.Context(), w)
 	})
 
-	fmt.Println("Listening on :3000")
-	http.ListenAndServe(":3000", nil)
+	/* SQLITE */
+
+	mux.HandleFunc("/sqlite", func(w http.ResponseWriter, r *http.Request) {
+		nameSearch := r.URL.Query().Get("name")
+
+		views.SqliteIndex(nameSearch).Render(r.Context(), w)
+	})
+
+	mux.Handle("POST /sqlite/users", http.HandlerFunc(handlers.AddSqliteUser))
+	mux.Handle("POST /sqlite/users/delete", http.HandlerFunc(handlers.DeleteSqliteUser))
+	mux.Handle("POST /sqlite/users/update", http.HandlerFunc(handlers.UpdateSqliteUser))
+	mux.HandleFunc("GET /sqlite/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		idInt, err := strconv.Atoi(id)
+		if err != nil {
+			panic(err)
+		}
+
+		views.SqliteUser("sqlite", idInt).Render(r.Context(), w)
+	})
+
+	mux.Handle("POST /sqlite/sprints", http.HandlerFunc(handlers.AddSqliteSprint))
+	mux.Handle("POST /sqlite/sprints/delete", http.HandlerFunc(handlers.DeleteSqliteSprint))
+	mux.Handle("POST /sqlite/sprints/update", http.HandlerFunc(handlers.UpdateSqliteSprint))
+	mux.HandleFunc("GET /sqlite/sprints/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		idInt, err := strconv.Atoi(id)
+		if err != nil {
+			panic(err)
+		}
+
+		views.SqliteSprint("sqlite", idInt).Render(r.Context(), w)
+	})
+
+	mux.Handle("POST /sqlite/projects", http.HandlerFunc(handlers.AddSqliteProject))
+	mux.Handle("POST /sqlite/projects/delete", http.HandlerFunc(handlers.DeleteSqliteProject))
+	mux.Handle("POST /sqlite/projects/update", http.HandlerFunc(handlers.UpdateSqliteProject))
+	mux.HandleFunc("GET /sqlite/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		idInt, err := strconv.Atoi(id)
+		if err != nil {
+			panic(err)
+		}
+
+		views.SqliteProject("sqlite", idInt).Render(r.Context(), w)
+	})
+
+	return mux
 }
 
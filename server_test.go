@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/testcontainers/testcontainers-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/fabiansefranek/dbi-perf-tests/db"
+)
+
+// The databases behind the web UI are opened once, by the first test that
+// needs them, and closed by TestMain.
+var (
+	postgresOnce, mongoOnce, sqliteOnce sync.Once
+	postgresErr, mongoErr, sqliteErr    error
+)
+
+func openPostgres(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("starts a Postgres container")
+	}
+	skipWithoutDocker(t)
+
+	postgresOnce.Do(func() {
+		connectionString, container, err := StartPostgres("test", ContainerLimits{})
+		if err != nil {
+			postgresErr = err
+			return
+		}
+		AtExit(func() { container.Terminate(context.Background()) })
+
+		conn, err := ConnectPostgresPool(connectionString, "test", nil, "public", 0, 0)
+		if err != nil {
+			postgresErr = err
+			return
+		}
+		AtExit(conn.Close)
+
+		postgresErr = InitializePostgres(conn, "public")
+		db.PostgresConn = conn
+	})
+	if postgresErr != nil {
+		t.Fatal(postgresErr)
+	}
+}
+
+func openMongo(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("starts a MongoDB container")
+	}
+	skipWithoutDocker(t)
+
+	mongoOnce.Do(func() {
+		connectionString, container, err := StartMongoDB(false, ContainerLimits{})
+		if err != nil {
+			mongoErr = err
+			return
+		}
+		AtExit(func() { testcontainers.TerminateContainer(container) })
+
+		client, err := ConnectMongoDB(connectionString, false, nil, 0, 0)
+		if err != nil {
+			mongoErr = err
+			return
+		}
+		AtExit(func() { client.Disconnect(context.Background()) })
+
+		database := client.Database("test")
+		for _, collection := range []string{"users", "projects", "sprints"} {
+			err = CreateMongoCollection(database, collection, nil)
+			if err != nil {
+				mongoErr = err
+				return
+			}
+		}
+		db.MongoConn = client
+		db.MongoDatabase = database.Name()
+	})
+	if mongoErr != nil {
+		t.Fatal(mongoErr)
+	}
+}
+
+// skipWithoutDocker skips the test when no container can be started.
+// testcontainers panics instead of skipping when it finds no Docker host.
+func skipWithoutDocker(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("Docker is not available: %v", r)
+		}
+	}()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
+
+func openSqlite(t *testing.T) {
+	t.Helper()
+
+	sqliteOnce.Do(func() {
+		conn, err := ConnectSqlite(":memory:")
+		if err != nil {
+			sqliteErr = err
+			return
+		}
+		AtExit(func() { conn.Close() })
+
+		sqliteErr = InitializeSqlite(conn)
+		db.SqliteConn = conn
+	})
+	if sqliteErr != nil {
+		t.Fatal(sqliteErr)
+	}
+}
+
+// routeBackend tells the route test where a backend's pages live and how to
+// look at its data directly.
+type routeBackend struct {
+	prefix string
+	index  string
+	// find returns the id of the record in table whose field has the value.
+	find func(t *testing.T, table, field, value string) string
+	// get returns the fields of the record in table with the id, formatted
+	// as the web UI shows them, or nil if there is no such record.
+	get func(t *testing.T, table, id string, fields ...string) []string
+}
+
+func TestPostgresRoutes(t *testing.T) {
+	openPostgres(t)
+	query := func(t *testing.T, query string, args ...any) []string {
+		t.Helper()
+		rows, _ := db.PostgresConn.Query(context.Background(), query, args...)
+		values, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) ([]string, error) {
+			values := make([]string, len(row.FieldDescriptions()))
+			dest := make([]any, len(values))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			return values, row.Scan(dest...)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return values[0]
+	}
+
+	testRoutes(t, routeBackend{
+		prefix: "/postgres",
+		index:  "/",
+		find: func(t *testing.T, table, field, value string) string {
+			t.Helper()
+			record := query(t, `select cast(id as text) from `+table+` where `+field+` = $1`, value)
+			if record == nil {
+				t.Fatalf("no %s with %s %q", table, field, value)
+			}
+			return record[0]
+		},
+		get: func(t *testing.T, table, id string, fields ...string) []string {
+			t.Helper()
+			return query(t, `select `+sqlTextColumns(fields)+` from `+table+` where id = $1`, id)
+		},
+	})
+}
+
+func TestMongoRoutes(t *testing.T) {
+	openMongo(t)
+	database := db.MongoConn.Database(db.MongoDatabase)
+
+	testRoutes(t, routeBackend{
+		prefix: "/mongo",
+		index:  "/mongo",
+		find: func(t *testing.T, table, field, value string) string {
+			t.Helper()
+			var document bson.M
+			err := database.Collection(table).FindOne(context.Background(), bson.M{field: value}).Decode(&document)
+			if err != nil {
+				t.Fatalf("no %s with %s %q: %v", table, field, value, err)
+			}
+			return document["_id"].(primitive.ObjectID).Hex()
+		},
+		get: func(t *testing.T, table, id string, fields ...string) []string {
+			t.Helper()
+			oid, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var document bson.M
+			err = database.Collection(table).FindOne(context.Background(), bson.M{"_id": oid}).Decode(&document)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			values := make([]string, len(fields))
+			for i, field := range fields {
+				values[i] = mongoValue(t, field, document[field])
+			}
+			return values
+		},
+	})
+}
+
+func TestSqliteRoutes(t *testing.T) {
+	openSqlite(t)
+	query := func(t *testing.T, query string, args ...any) []string {
+		t.Helper()
+		rows, err := db.SqliteConn.QueryContext(context.Background(), query, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			if rows.Err() != nil {
+				t.Fatal(rows.Err())
+			}
+			return nil
+		}
+
+		columns, _ := rows.Columns()
+		values := make([]string, len(columns))
+		dest := make([]any, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+
+	testRoutes(t, routeBackend{
+		prefix: "/sqlite",
+		index:  "/sqlite",
+		find: func(t *testing.T, table, field, value string) string {
+			t.Helper()
+			record := query(t, `select cast(id as text) from `+table+` where `+field+` = ?`, value)
+			if record == nil {
+				t.Fatalf("no %s with %s %q", table, field, value)
+			}
+			return record[0]
+		},
+		get: func(t *testing.T, table, id string, fields ...string) []string {
+			t.Helper()
+			return query(t, `select `+sqlTextColumns(fields)+` from `+table+` where id = ?`, id)
+		},
+	})
+}
+
+func TestIndexRedirectsWithoutPostgres(t *testing.T) {
+	conn := db.PostgresConn
+	db.PostgresConn = nil
+	defer func() { db.PostgresConn = conn }()
+
+	response := serve(t, NewServer(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if response.Code != http.StatusTemporaryRedirect || response.Header().Get("Location") != "/sqlite" {
+		t.Errorf("GET / answered %d to %q, want a redirect to /sqlite", response.Code, response.Header().Get("Location"))
+	}
+}
+
+// testRoutes walks through every route of a backend: it creates a user, a
+// project and a sprint, reads, updates and finally deletes them again, and
+// checks the stored data and the rendered pages after every step.
+func testRoutes(t *testing.T, backend routeBackend) {
+	server := NewServer()
+
+	/* USERS */
+
+	postForm(t, server, backend, "/users", url.Values{"username": {"ada"}, "firstName": {"Ada"}, "lastName": {"Lovelace"}})
+	userID := backend.find(t, "users", "username", "ada")
+	expectRecord(t, backend, "users", userID, []string{"first_name", "last_name"}, []string{"Ada", "Lovelace"})
+	expectPage(t, server, backend.index, "ada", "Lovelace")
+	expectPage(t, server, backend.prefix+"/users/"+userID, "ada", "Lovelace")
+
+	postForm(t, server, backend, "/users/update", url.Values{"id": {userID}, "username": {"ada"}, "firstName": {"Ada"}, "lastName": {"King"}})
+	expectRecord(t, backend, "users", userID, []string{"username", "first_name", "last_name"}, []string{"ada", "Ada", "King"})
+
+	/* PROJECTS */
+
+	postForm(t, server, backend, "/projects", url.Values{"name": {"Engine"}, "identifier": {"ENG"}, "invite_code": {"abc"}, "sprint_duration": {"14"}, "owner_id": {userID}})
+	projectID := backend.find(t, "projects", "name", "Engine")
+	expectRecord(t, backend, "projects", projectID, []string{"identifier", "invite_code", "sprint_duration", "owner_id"}, []string{"ENG", "abc", "14", userID})
+	expectPage(t, server, backend.index+"?name=Engine", "Engine", "ENG")
+	expectPage(t, server, backend.prefix+"/projects/"+projectID, "Engine", userID)
+
+	postForm(t, server, backend, "/projects/update", url.Values{"id": {projectID}, "name": {"Analytical Engine"}, "identifier": {"AEN"}, "invite_code": {"xyz"}, "sprint_duration": {"21"}, "owner_id": {userID}})
+	expectRecord(t, backend, "projects", projectID, []string{"name", "identifier", "invite_code", "sprint_duration", "owner_id"}, []string{"Analytical Engine", "AEN", "xyz", "21", userID})
+
+	/* SPRINTS */
+
+	postForm(t, server, backend, "/sprints", url.Values{"name": {"First"}, "start_date": {"1000"}, "end_date": {"2000"}, "project_id": {projectID}})
+	sprintID := backend.find(t, "sprints", "name", "First")
+	expectRecord(t, backend, "sprints", sprintID, []string{"start_date", "end_date", "project_id"}, []string{"1000", "2000", projectID})
+	expectPage(t, server, backend.index, "First")
+	expectPage(t, server, backend.prefix+"/sprints/"+sprintID, "First", "1000", projectID)
+
+	postForm(t, server, backend, "/sprints/update", url.Values{"id": {sprintID}, "name": {"Second"}, "start_date": {"1500"}, "end_date": {"2500"}})
+	expectRecord(t, backend, "sprints", sprintID, []string{"name", "start_date", "end_date", "project_id"}, []string{"Second", "1500", "2500", projectID})
+
+	/* DELETE */
+
+	postForm(t, server, backend, "/sprints/delete", url.Values{"id": {sprintID}})
+	expectDeleted(t, backend, "sprints", sprintID)
+	postForm(t, server, backend, "/projects/delete", url.Values{"id": {projectID}})
+	expectDeleted(t, backend, "projects", projectID)
+	postForm(t, server, backend, "/users/delete", url.Values{"id": {userID}})
+	expectDeleted(t, backend, "users", userID)
+}
+
+// serve runs the request against the server and turns a panicking handler
+// into a test failure.
+func serve(t *testing.T, server http.Handler, request *http.Request) (response *httptest.ResponseRecorder) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("%s %s panicked: %v", request.Method, request.URL, r)
+		}
+	}()
+
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+// postForm submits a form the way the browser does and expects to be sent
+// back to the backend's index page.
+func postForm(t *testing.T, server http.Handler, backend routeBackend, path string, form url.Values) {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, backend.prefix+path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response := serve(t, server, request)
+	if response.Code != http.StatusTemporaryRedirect || response.Header().Get("Location") != backend.index {
+		t.Fatalf("POST %s%s answered %d to %q, want a redirect to %s", backend.prefix, path, response.Code, response.Header().Get("Location"), backend.index)
+	}
+}
+
+func expectPage(t *testing.T, server http.Handler, path string, contents ...string) {
+	t.Helper()
+	response := serve(t, server, httptest.NewRequest(http.MethodGet, path, nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("GET %s answered %d", path, response.Code)
+	}
+
+	body := response.Body.String()
+	for _, content := range contents {
+		if !strings.Contains(body, content) {
+			t.Errorf("GET %s does not show %q", path, content)
+		}
+	}
+}
+
+func expectRecord(t *testing.T, backend routeBackend, table, id string, fields, want []string) {
+	t.Helper()
+	got := backend.get(t, table, id, fields...)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s %s has %v = %q, want %q", table, id, fields, got, want)
+	}
+}
+
+func expectDeleted(t *testing.T, backend routeBackend, table, id string) {
+	t.Helper()
+	field := "name"
+	if table == "users" {
+		field = "username"
+	}
+
+	if record := backend.get(t, table, id, field); record != nil {
+		t.Errorf("%s %s was not deleted", table, id)
+	}
+}
+
+// sqlTextColumns selects the fields as text, which Postgres and SQLite both
+// scan into strings.
+func sqlTextColumns(fields []string) string {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = "cast(" + field + " as text)"
+	}
+	return strings.Join(columns, ", ")
+}
+
+// mongoValue formats a field of a document and fails the test if it is not
+// stored with the type the rest of the code expects: references as
+// ObjectIDs and numbers as integers.
+func mongoValue(t *testing.T, field string, value any) string {
+	t.Helper()
+	switch value := value.(type) {
+	case primitive.ObjectID:
+		return value.Hex()
+	case int32, int64:
+		return fmt.Sprint(value)
+	case string:
+		switch field {
+		case "owner_id", "project_id", "sprint_duration", "start_date", "end_date":
+			t.Errorf("%s is stored as the string %q", field, value)
+		}
+		return value
+	default:
+		t.Errorf("%s is stored as %T", field, value)
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+/* TIME SPLIT */
+
+// The duration of a measurement covers the driver encoding the request, the
+// network, the server executing it and the driver decoding the response.
+// The split takes the server's part from its own statistics, pg_stat_statements
+// for PostgreSQL and the profiler of the variant's database for MongoDB, and
+// the client's from the CPU time the benchmark's process spent less that of
+// the sampler, and leaves the rest to the network and waiting, such as for
+// locks or the disk. Both are approximations: the process's CPU time is not
+// only the driver's, and the profiler counts in whole milliseconds. The
+// profiler is only on while the size runs are measured, but there it writes
+// an entry for every operation, so the durations the MongoDB splits divide
+// carry its overhead. Variants
+// that run in the process, like SQLite, spend all of their time as client.
+
+// splitParts are the parts of the split in the order the bars stack.
+var splitParts = []string{"Server", "Client", "Network / wait"}
+
+// TimeSplit divides the duration of a measurement.
+type TimeSplit struct {
+	Server  time.Duration `json:"server_ns"`
+	Client  time.Duration `json:"client_ns"`
+	Network time.Duration `json:"network_ns"`
+}
+
+// SplitDuration splits the duration of a measurement of the variant. The CPU
+// time of the client is measured around the whole operation, so it is capped
+// at what the server leaves of the duration. It returns nil if the variant
+// has a server that does not say how long it took.
+func SplitDuration(variant Variant, duration time.Duration, client time.Duration, usage *ServerUsage) *TimeSplit {
+	if variant.InProcess {
+		return &TimeSplit{Client: duration}
+	}
+	if usage == nil || usage.Execution == nil {
+		return nil
+	}
+
+	split := &TimeSplit{Server: min(*usage.Execution, duration)}
+	split.Client = min(client, duration-split.Server)
+	split.Network = duration - split.Server - split.Client
+	return split
+}
+
+// CreateSplitChart stacks the split of every variant's measurement of an
+// operation into a bar, in milliseconds. Variants without a split are left
+// out.
+func CreateSplitChart(title string, variants []Variant, splits map[string]*TimeSplit) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    title,
+			Subtitle: "Approximate: client is the process's CPU time less the sampler's, MongoDB is measured with its profiler on, which it pays for, and rounds server time to whole ms",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "Time (ms)"}),
+	)
+
+	var names []string
+	data := make([][]opts.BarData, len(splitParts))
+	for _, variant := range variants {
+		split, ok := splits[variant.Name]
+		if !ok || split == nil {
+			continue
+		}
+
+		names = append(names, variant.Name)
+		for i, part := range []time.Duration{split.Server, split.Client, split.Network} {
+			data[i] = append(data[i], opts.BarData{Value: float64(part.Microseconds()) / 1000})
+		}
+	}
+
+	bar.SetXAxis(names)
+	for i, part := range splitParts {
+		bar.AddSeries(part, data[i], charts.WithBarChartOpts(opts.BarChart{Stack: "duration"}))
+	}
+	return bar
+}
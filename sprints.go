@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+/* SPRINTS */
+
+// Adding, updating and removing a single sprint of a project is where the
+// embedded model has to change an array inside a document, while the other
+// models change a row or document of its own.
+
+// SprintFuncs change and read the sprints of the project with an identifier.
+type SprintFuncs struct {
+	Add    func(identifier string, sprint models.Sprint) (time.Duration, error)
+	Update func(identifier string, name string) (time.Duration, error)
+	Remove func(identifier string, name string) (time.Duration, error)
+	// Read returns the project together with all of its sprints.
+	Read func(identifier string) (Measurement, error)
+}
+
+// withSprints adds the sprint operations to the operations of a variant. They
+// work on the fourth project.
+func withSprints(operations map[string]OperationFunc, sprints SprintFuncs) map[string]OperationFunc {
+	operations["Add sprint"] = func(projects []models.Project) (Measurement, error) {
+		return timed(sprints.Add(projects[3].Identifier, NextSprint(projects[3])))
+	}
+	operations["Update sprint"] = func(projects []models.Project) (Measurement, error) {
+		return timed(sprints.Update(projects[3].Identifier, projects[3].Sprints[0].Name))
+	}
+	operations["Remove sprint"] = func(projects []models.Project) (Measurement, error) {
+		return timed(sprints.Remove(projects[3].Identifier, NextSprint(projects[3]).Name))
+	}
+	return operations
+}
+
+// noSprintRemoved is the error of a removal that found no sprint of that
+// name, which would otherwise measure a no-op.
+func noSprintRemoved(identifier string, name string) error {
+	return fmt.Errorf("project %s has no sprint %s to remove", identifier, name)
+}
+
+// NextSprint returns the sprint "Add sprint" appends to a project. It starts
+// when the project's first sprint ends and lasts the project's sprint
+// duration in days.
+func NextSprint(project models.Project) models.Sprint {
+	startDate := project.Sprints[0].EndDate
+	return models.Sprint{
+		Name:      project.Name + "-next",
+		StartDate: startDate,
+		EndDate:   startDate + int64(project.SprintDuration)*(60*60*24),
+	}
+}
+
+/* POSTGRES */
+
+func InsertPostgresSprint(conn *pgxpool.Pool, identifier string, sprint models.Sprint) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = conn.Exec(context.Background(),
+		`INSERT INTO sprints (name, project_id, start_date, end_date) SELECT $2, id, $3, $4 FROM projects WHERE identifier = $1;`,
+		identifier, sprint.Name, sprint.StartDate, sprint.EndDate)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+// UpdatePostgresSprint moves the end date of the project's sprints with the
+// name a day later.
+func UpdatePostgresSprint(conn *pgxpool.Pool, identifier string, name string) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = conn.Exec(context.Background(),
+		`UPDATE sprints SET end_date = end_date + (60*60*24) WHERE name = $2 AND project_id IN (SELECT id FROM projects WHERE identifier = $1);`,
+		identifier, name)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+func DeletePostgresSprint(conn *pgxpool.Pool, identifier string, name string) (duration time.Duration, err error) {
+	now := time.Now()
+	tag, err := conn.Exec(context.Background(),
+		`DELETE FROM sprints WHERE name = $2 AND project_id IN (SELECT id FROM projects WHERE identifier = $1);`,
+		identifier, name)
+	duration = time.Since(now)
+	if err != nil {
+		return duration, err
+	}
+	if tag.RowsAffected() == 0 {
+		return duration, noSprintRemoved(identifier, name)
+	}
+	return duration, nil
+}
+
+func ReadPostgresProject(conn *pgxpool.Pool, identifier string) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = queryPostgres(conn, `SELECT * FROM projects JOIN sprints ON sprints.project_id = projects.id WHERE projects.identifier = $1;`, identifier)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+/* SQLITE */
+
+func InsertSqliteSprint(conn *sql.DB, identifier string, sprint models.Sprint) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = conn.ExecContext(context.Background(),
+		`INSERT INTO sprints (name, project_id, start_date, end_date) SELECT ?2, id, ?3, ?4 FROM projects WHERE identifier = ?1;`,
+		identifier, sprint.Name, sprint.StartDate, sprint.EndDate)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+func UpdateSqliteSprint(conn *sql.DB, identifier string, name string) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = conn.ExecContext(context.Background(),
+		`UPDATE sprints SET end_date = end_date + (60*60*24) WHERE name = ?2 AND project_id IN (SELECT id FROM projects WHERE identifier = ?1);`,
+		identifier, name)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+func DeleteSqliteSprint(conn *sql.DB, identifier string, name string) (duration time.Duration, err error) {
+	now := time.Now()
+	result, err := conn.ExecContext(context.Background(),
+		`DELETE FROM sprints WHERE name = ?2 AND project_id IN (SELECT id FROM projects WHERE identifier = ?1);`,
+		identifier, name)
+	duration = time.Since(now)
+	if err != nil {
+		return duration, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return duration, err
+	}
+	if removed == 0 {
+		return duration, noSprintRemoved(identifier, name)
+	}
+	return duration, nil
+}
+
+func ReadSqliteProject(conn *sql.DB, identifier string) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = querySqlite(conn, `SELECT * FROM projects JOIN sprints ON sprints.project_id = projects.id WHERE projects.identifier = ?;`, identifier)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+/* MONGODB */
+
+// PushMongoSprint appends the sprint to the embedded sprints of the project.
+func PushMongoSprint(database *mongo.Database, collection string, identifier string, sprint models.Sprint) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = database.Collection(collection).UpdateMany(context.Background(),
+		bson.M{"identifier": identifier},
+		bson.M{"$push": bson.M{"sprints": bson.M{
+			"name":       sprint.Name,
+			"start_date": sprint.StartDate,
+			"end_date":   sprint.EndDate,
+		}}})
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+// UpdateMongoSprint moves the end date of the embedded sprints with the name
+// a day later. The array filter picks the sprints inside the project's
+// array, so every sprint of that name changes and not only the first one as
+// with the positional $ operator.
+func UpdateMongoSprint(database *mongo.Database, collection string, identifier string, name string) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = database.Collection(collection).UpdateMany(context.Background(),
+		bson.M{"identifier": identifier},
+		bson.M{"$inc": bson.M{"sprints.$[sprint].end_date": (60 * 60 * 24)}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"sprint.name": name}}}))
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+// PullMongoSprint removes the embedded sprints with the name from the
+// project.
+func PullMongoSprint(database *mongo.Database, collection string, identifier string, name string) (duration time.Duration, err error) {
+	now := time.Now()
+	result, err := database.Collection(collection).UpdateMany(context.Background(),
+		bson.M{"identifier": identifier},
+		bson.M{"$pull": bson.M{"sprints": bson.M{"name": name}}})
+	duration = time.Since(now)
+	if err != nil {
+		return duration, err
+	}
+	if result.ModifiedCount == 0 {
+		return duration, noSprintRemoved(identifier, name)
+	}
+	return duration, nil
+}
+
+// ReadMongoProject reads the project document, which holds all of its
+// sprints.
+func ReadMongoProject(database *mongo.Database, collection string, identifier string) (result Measurement, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	cursor, err := database.Collection(collection).Find(ctx, bson.M{"identifier": identifier})
+	if err != nil {
+		return result, err
+	}
+	result.Rows, result.Bytes, err = drainCursor(ctx, cursor)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// projectIdsWithReferencing looks up the ids of the referencing projects
+// with the identifier, which the sprints point at.
+func projectIdsWithReferencing(ctx context.Context, database *mongo.Database, identifier string) (ids []primitive.ObjectID, err error) {
+	values, err := database.Collection("projects").Distinct(ctx, "_id", bson.M{"identifier": identifier})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func InsertMongoSprintWithReferencing(database *mongo.Database, identifier string, sprint models.Sprint) (duration time.Duration, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	ids, err := projectIdsWithReferencing(ctx, database, identifier)
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	for _, id := range ids {
+		_, err = database.Collection("sprints").InsertOne(ctx, bson.M{
+			"name":       sprint.Name,
+			"project":    id,
+			"start_date": sprint.StartDate,
+			"end_date":   sprint.EndDate,
+		})
+		if err != nil {
+			return time.Since(now), err
+		}
+	}
+	return time.Since(now), nil
+}
+
+func UpdateMongoSprintWithReferencing(database *mongo.Database, identifier string, name string) (duration time.Duration, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	ids, err := projectIdsWithReferencing(ctx, database, identifier)
+	if err != nil || len(ids) == 0 {
+		return time.Since(now), err
+	}
+
+	_, err = database.Collection("sprints").UpdateMany(ctx,
+		bson.M{"project": bson.M{"$in": ids}, "name": name},
+		bson.M{"$inc": bson.M{"end_date": (60 * 60 * 24)}})
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+func DeleteMongoSprintWithReferencing(database *mongo.Database, identifier string, name string) (duration time.Duration, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	ids, err := projectIdsWithReferencing(ctx, database, identifier)
+	if err != nil {
+		return time.Since(now), err
+	}
+
+	result, err := database.Collection("sprints").DeleteMany(ctx, bson.M{"project": bson.M{"$in": ids}, "name": name})
+	duration = time.Since(now)
+	if err != nil {
+		return duration, err
+	}
+	if result.DeletedCount == 0 {
+		return duration, noSprintRemoved(identifier, name)
+	}
+	return duration, nil
+}
+
+// ReadMongoProjectWithReferencing reads the project and then its sprints.
+func ReadMongoProjectWithReferencing(database *mongo.Database, identifier string) (result Measurement, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	cursor, err := database.Collection("projects").Find(ctx, bson.M{"identifier": identifier})
+	if err != nil {
+		return result, err
+	}
+
+	var projects []bson.Raw
+	err = cursor.All(ctx, &projects)
+	if err != nil {
+		return result, err
+	}
+
+	ids := make(bson.A, 0, len(projects))
+	for _, project := range projects {
+		ids = append(ids, project.Lookup("_id"))
+		result.Bytes += int64(len(project))
+	}
+
+	cursor, err = database.Collection("sprints").Find(ctx, bson.M{"project": bson.M{"$in": ids}})
+	if err != nil {
+		return result, err
+	}
+	rows, bytes, err := drainCursor(ctx, cursor)
+	result.Duration = time.Since(now)
+	result.Rows, result.Bytes = rows, result.Bytes+bytes
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
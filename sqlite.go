@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+/* SQLITE */
+
+func ConnectSqlite(dsn string) (conn *sql.DB, err error) {
+	// The cascades need foreign keys on every connection, including those
+	// that replace a broken one.
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	conn, err = sql.Open("sqlite", dsn+separator+"_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+
+	// Every connection to ":memory:" opens its own empty database, so all
+	// queries have to go through the same one.
+	conn.SetMaxOpenConns(1)
+
+	return conn, nil
+}
+
+func InitializeSqlite(conn *sql.DB) (err error) {
+	_, err = conn.Exec(
+		`CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				username VARCHAR(255) NOT NULL,
+				first_name VARCHAR(255) NOT NULL,
+				last_name VARCHAR(255) NOT NULL
+			);
+
+			CREATE TABLE IF NOT EXISTS projects (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(255) NOT NULL,
+				identifier VARCHAR(48) NOT NULL,
+				invite_code VARCHAR(128) NOT NULL,
+				sprint_duration INT NOT NULL,
+				owner_id INT NOT NULL,
+				CONSTRAINT fk_owner FOREIGN KEY(owner_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+
+			CREATE TABLE IF NOT EXISTS sprints (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(255) NOT NULL,
+				project_id INT NOT NULL,
+				start_date INT NOT NULL,
+				end_date INT NOT NULL,
+				CONSTRAINT fk_project FOREIGN KEY(project_id) REFERENCES projects(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS sprints_start_date_id ON sprints (start_date, id);
+			CREATE UNIQUE INDEX IF NOT EXISTS projects_identifier ON projects (identifier);
+			CREATE INDEX IF NOT EXISTS sprints_project_id ON sprints (project_id);
+			CREATE INDEX IF NOT EXISTS projects_owner_id ON projects (owner_id);
+`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ResetSqlite empties all tables and restarts their AUTOINCREMENT counters.
+func ResetSqlite(conn *sql.DB) (err error) {
+	_, err = conn.ExecContext(context.Background(), `
+		DELETE FROM sprints;
+		DELETE FROM projects;
+		DELETE FROM users;
+		DELETE FROM sqlite_sequence WHERE name IN ('users', 'projects', 'sprints');
+	`)
+	return err
+}
+
+// CountSqlite returns the number of rows in all tables.
+func CountSqlite(conn *sql.DB) (count int64, err error) {
+	err = conn.QueryRowContext(context.Background(), `SELECT (SELECT COUNT(*) FROM users) + (SELECT COUNT(*) FROM projects) + (SELECT COUNT(*) FROM sprints);`).Scan(&count)
+	return count, err
+}
+
+/* PERFORMANCE TESTS */
+
+func InsertSqlite(conn *sql.DB, projects []models.Project) (duration time.Duration, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	// Owners shared by several projects are stored once.
+	userIds := make(map[string]int64)
+	for _, project := range projects {
+		userId, ok := userIds[project.Owner.Username]
+		if !ok {
+			result, err := conn.ExecContext(ctx,
+				`INSERT INTO users (username, first_name, last_name) VALUES (?, ?, ?);`,
+				project.Owner.Username, project.Owner.FirstName, project.Owner.LastName)
+			if err != nil {
+				return time.Since(now), err
+			}
+
+			userId, err = result.LastInsertId()
+			if err != nil {
+				return time.Since(now), err
+			}
+			userIds[project.Owner.Username] = userId
+		}
+
+		result, err := conn.ExecContext(ctx,
+			`INSERT INTO projects (name, identifier, invite_code, sprint_duration, owner_id) VALUES (?, ?, ?, ?, ?);`,
+			project.Name, project.Identifier, project.InviteCode, project.SprintDuration, userId)
+		if err != nil {
+			return time.Since(now), err
+		}
+
+		projectId, err := result.LastInsertId()
+		if err != nil {
+			return time.Since(now), err
+		}
+
+		for _, sprint := range project.Sprints {
+			_, err = conn.ExecContext(ctx,
+				`INSERT INTO sprints (name, project_id, start_date, end_date) VALUES (?, ?, ?, ?);`,
+				sprint.Name, projectId, sprint.StartDate, sprint.EndDate)
+			if err != nil {
+				return time.Since(now), err
+			}
+		}
+	}
+
+	return time.Since(now), nil
+}
+
+// querySqlite runs a query and steps through every row, since database/sql
+// only reads rows from SQLite as they are requested. Bytes are counted in
+// the driver's representation of the values, which is text for numbers.
+func querySqlite(conn *sql.DB, query string, args ...any) (count int64, bytes int64, err error) {
+	rows, err := conn.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		err = rows.Scan(dest...)
+		if err != nil {
+			return count, bytes, err
+		}
+
+		count++
+		for _, value := range values {
+			bytes += int64(len(value))
+		}
+	}
+
+	return count, bytes, rows.Err()
+}
+
+func FindSqlite(conn *sql.DB) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = querySqlite(conn, `SELECT * FROM sprints INNER JOIN projects ON sprints.project_id = projects.id INNER JOIN users ON projects.owner_id = users.id;`)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func FindSqliteWithFilter(conn *sql.DB, threshold int) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = querySqlite(conn, `SELECT * FROM sprints INNER JOIN projects ON sprints.project_id = projects.id INNER JOIN users ON projects.owner_id = users.id WHERE projects.sprint_duration > ?;`, threshold)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func FindSqliteWithFilterAndProjection(conn *sql.DB, threshold int) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = querySqlite(conn, `SELECT users.username, projects.name, sprints.name FROM sprints INNER JOIN projects ON sprints.project_id = projects.id INNER JOIN users ON projects.owner_id = users.id WHERE projects.sprint_duration > ?;`, threshold)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func FindSqliteWithAggregation(conn *sql.DB) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = querySqlite(conn, `SELECT users.username AS owner, COUNT(*) AS count FROM projects INNER JOIN users ON projects.owner_id = users.id GROUP BY owner;`)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func FindSqliteWithFilterAndProjectionAndSort(conn *sql.DB, threshold int) (result Measurement, err error) {
+	now := time.Now()
+	result.Rows, result.Bytes, err = querySqlite(conn, `SELECT users.username, projects.name, sprints.name FROM sprints INNER JOIN projects ON sprints.project_id = projects.id INNER JOIN users ON projects.owner_id = users.id WHERE projects.sprint_duration > ? ORDER BY sprints.start_date DESC;`, threshold)
+	result.Duration = time.Since(now)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func UpdateSqlite(conn *sql.DB) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = conn.ExecContext(context.Background(), `UPDATE sprints SET start_date = start_date + (60*60*24)`)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
+
+func DeleteSqlite(conn *sql.DB) (duration time.Duration, err error) {
+	now := time.Now()
+	_, err = conn.ExecContext(context.Background(), `DELETE FROM sprints; DELETE FROM projects; DELETE FROM users;`)
+	if err != nil {
+		return time.Since(now), err
+	}
+	return time.Since(now), nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/* STORAGE */
+
+// TableStorage is the space one table or collection of a variant takes up.
+// Total includes the indexes.
+type TableStorage struct {
+	Table      string
+	Total      int64
+	Indexes    int64
+	AverageRow int64
+}
+
+// StorageFunc returns the space every table or collection of a variant takes
+// up.
+type StorageFunc func() ([]TableStorage, error)
+
+// MeasureStorage measures the storage of every variant right after the
+// inserts of a size. It returns a row per table or collection name and a
+// total row, and adds the total of every variant to its chart series in
+// KiB. Variants without a table of that name show "-".
+func MeasureStorage(variants []Variant, size int, data map[string][]opts.LineData) (rows [][]string, err error) {
+	var tables []string
+	storage := make(map[string]map[string]TableStorage)
+	totals := make(map[string]int64)
+	for _, variant := range variants {
+		if variant.Storage == nil {
+			continue
+		}
+
+		results, err := variant.Storage()
+		if err != nil {
+			return nil, fmt.Errorf("measuring the storage of %s: %w", variant.Name, err)
+		}
+
+		storage[variant.Name] = make(map[string]TableStorage)
+		for _, result := range results {
+			if !slices.Contains(tables, result.Table) {
+				tables = append(tables, result.Table)
+			}
+			storage[variant.Name][result.Table] = result
+			totals[variant.Name] += result.Total
+		}
+		data[variant.Name] = append(data[variant.Name], opts.LineData{Value: float64(totals[variant.Name]) / 1024})
+	}
+
+	for _, table := range tables {
+		row := []string{fmt.Sprint(size), table}
+		for _, variant := range variants {
+			result, ok := storage[variant.Name][table]
+			if !ok {
+				row = append(row, "-")
+				continue
+			}
+			row = append(row, fmt.Sprintf("%s (indexes %s, %s per row)", FormatBytes(result.Total), FormatBytes(result.Indexes), FormatBytes(result.AverageRow)))
+		}
+		rows = append(rows, row)
+	}
+
+	row := []string{fmt.Sprint(size), "Total"}
+	for _, variant := range variants {
+		if _, ok := storage[variant.Name]; !ok {
+			row = append(row, "-")
+			continue
+		}
+		row = append(row, FormatBytes(totals[variant.Name]))
+	}
+	return append(rows, row), nil
+}
+
+// relationalTables are the tables of the Postgres and SQLite models.
+var relationalTables = []string{"users", "projects", "sprints"}
+
+/* POSTGRES */
+
+// StoragePostgres averages the size of the rows themselves, without the
+// per-row header and alignment the table pages add on top.
+func StoragePostgres(conn *pgxpool.Pool) StorageFunc {
+	return func() (results []TableStorage, err error) {
+		for _, table := range relationalTables {
+			result := TableStorage{Table: table}
+			err = conn.QueryRow(context.Background(),
+				`SELECT pg_total_relation_size($1::TEXT::REGCLASS), pg_indexes_size($1::TEXT::REGCLASS), COALESCE((SELECT AVG(pg_column_size(t.*))::BIGINT FROM `+pgx.Identifier{table}.Sanitize()+` t), 0);`,
+				table).Scan(&result.Total, &result.Indexes, &result.AverageRow)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+		return results, nil
+	}
+}
+
+/* SQLITE */
+
+// StorageSqlite adds up the pages of every table and its indexes, as the
+// dbstat virtual table reports them. The average row is the average payload
+// of the table's cells.
+func StorageSqlite(conn *sql.DB) StorageFunc {
+	return func() (results []TableStorage, err error) {
+		for _, table := range relationalTables {
+			result := TableStorage{Table: table}
+			err = conn.QueryRowContext(context.Background(), `
+				SELECT
+					COALESCE(SUM(pgsize), 0),
+					COALESCE(SUM(CASE WHEN name != ?1 THEN pgsize END), 0),
+					COALESCE(SUM(CASE WHEN name = ?1 AND pagetype = 'leaf' THEN payload END) / NULLIF(SUM(CASE WHEN name = ?1 AND pagetype = 'leaf' THEN ncell END), 0), 0)
+				FROM dbstat WHERE name IN (SELECT name FROM sqlite_schema WHERE tbl_name = ?1);`,
+				table).Scan(&result.Total, &result.Indexes, &result.AverageRow)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+		return results, nil
+	}
+}
+
+/* MONGODB */
+
+// StorageMongo reports the storage size of every collection, which is what
+// WiredTiger has written to disk, compressed. It asks the server to write a
+// checkpoint first, since freshly inserted documents would otherwise only be
+// in the cache. Servers that do not allow that, like Atlas, report the size
+// of the last checkpoint.
+func StorageMongo(database *mongo.Database, collections ...string) StorageFunc {
+	return func() (results []TableStorage, err error) {
+		ctx := context.Background()
+		database.Client().Database("admin").RunCommand(ctx, bson.D{{Key: "fsync", Value: 1}})
+
+		for _, collection := range collections {
+			cursor, err := database.Collection(collection).Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$collStats", Value: bson.M{"storageStats": bson.M{}}}},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			var stats []struct {
+				StorageStats struct {
+					StorageSize    float64 `bson:"storageSize"`
+					TotalIndexSize float64 `bson:"totalIndexSize"`
+					AvgObjSize     float64 `bson:"avgObjSize"`
+				} `bson:"storageStats"`
+			}
+			err = cursor.All(ctx, &stats)
+			if err != nil {
+				return nil, err
+			}
+
+			result := TableStorage{Table: collection}
+			for _, stat := range stats {
+				result.Total += int64(stat.StorageStats.StorageSize + stat.StorageStats.TotalIndexSize)
+				result.Indexes += int64(stat.StorageStats.TotalIndexSize)
+				result.AverageRow = int64(stat.StorageStats.AvgObjSize)
+			}
+			results = append(results, result)
+		}
+		return results, nil
+	}
+}
@@ -0,0 +1,852 @@
+package main
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// connectTimeout bounds how long we wait for a target before giving up on it.
+const connectTimeout = 10 * time.Second
+
+// Target is a database the benchmark runs against. Managed targets are
+// started as testcontainers, all others are reached through their DSN.
+type Target struct {
+	Name string `json:"name"`
+	DSN  string `json:"dsn"`
+	// Database overrides the database named in the DSN.
+	Database string `json:"database"`
+	Managed  bool   `json:"managed"`
+	// Optional targets are dropped from the results when they cannot be
+	// reached instead of aborting the run.
+	Optional bool `json:"optional"`
+	// ServerAPI pins the Stable API version, which Atlas requires.
+	ServerAPI   bool   `json:"server_api"`
+	TLS         bool   `json:"tls"`
+	TLSCAFile   string `json:"tls_ca"`
+	TLSInsecure bool   `json:"tls_insecure"`
+	// Variants selects which data models are run against the target, all of
+	// them if empty: "embedded", "index" and "referencing" on Mongo, "plain"
+	// and "index" on Postgres.
+	Variants []string `json:"variants"`
+	// Targets that are not managed get a fresh database for every run,
+	// which is dropped on exit. ReuseDatabase works in the configured
+	// database instead, for users that may not create databases.
+	ReuseDatabase bool `json:"reuse_database"`
+	// MinPool and MaxPool size the connection pool of the benchmark client,
+	// zero keeps the driver's default.
+	MinPool int `json:"min_pool"`
+	MaxPool int `json:"max_pool"`
+	// ReplicaSet starts a managed Mongo target as a single-node replica
+	// set, which change streams need. Remote targets are what they are.
+	ReplicaSet bool `json:"replica_set"`
+	// Notifications reserves the target for the notification benchmark and
+	// leaves it out of every other measurement, so that a replica set that
+	// is only there for change streams does not skew the rest.
+	Notifications bool `json:"notifications"`
+	// CPUs and Memory cap the container of a managed target, and Cache sizes
+	// shared_buffers on Postgres and the WiredTiger cache on Mongo. Sizes
+	// are given like "512m" or "2g". Empty or zero keeps the defaults, which
+	// on Mongo size the cache from the memory limit or else the host's RAM.
+	CPUs   float64 `json:"cpus"`
+	Memory string  `json:"memory"`
+	Cache  string  `json:"cache"`
+
+	// Missing is set when a secret of an optional target is not available.
+	Missing error `json:"-"`
+}
+
+// ParseTarget reads a target spec of comma separated options, for example
+//
+//	name=Mongo (Atlas),optional,server-api,dsn=mongodb+srv://host/
+//
+// dsn has to come last since it takes the rest of the spec, so that
+// connection strings with several hosts keep their commas. Data models are
+// joined with "+", as in variants=embedded+referencing, min-pool= and
+// max-pool= size the client's connection pool, and cpus=, memory= and cache=
+// limit a managed container.
+func ParseTarget(spec string) (target Target, err error) {
+	for spec != "" {
+		var option string
+		if strings.HasPrefix(spec, "dsn=") {
+			option, spec = spec, ""
+		} else {
+			option, spec, _ = strings.Cut(spec, ",")
+		}
+
+		key, value, hasValue := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "name":
+			target.Name = value
+		case "dsn":
+			target.DSN = value
+		case "database":
+			target.Database = value
+		case "tls-ca":
+			target.TLS = true
+			target.TLSCAFile = value
+		case "variants":
+			target.Variants = strings.Split(value, "+")
+		case "min-pool", "max-pool":
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 0 {
+				return target, fmt.Errorf("target option %s needs a pool size, not %q", key, value)
+			}
+			if key == "min-pool" {
+				target.MinPool = size
+			} else {
+				target.MaxPool = size
+			}
+		case "cpus":
+			cpus, err := strconv.ParseFloat(value, 64)
+			if err != nil || cpus < 0 {
+				return target, fmt.Errorf("target option cpus needs a number of CPUs, not %q", value)
+			}
+			target.CPUs = cpus
+		case "memory":
+			target.Memory = value
+		case "cache":
+			target.Cache = value
+		case "managed", "optional", "server-api", "tls", "tls-insecure", "reuse-database", "replica-set", "notifications":
+			if hasValue {
+				return target, fmt.Errorf("target option %q does not take a value", key)
+			}
+			switch key {
+			case "managed":
+				target.Managed = true
+			case "optional":
+				target.Optional = true
+			case "server-api":
+				target.ServerAPI = true
+			case "tls":
+				target.TLS = true
+			case "tls-insecure":
+				target.TLS = true
+				target.TLSInsecure = true
+			case "reuse-database":
+				target.ReuseDatabase = true
+			case "replica-set":
+				target.ReplicaSet = true
+			case "notifications":
+				target.Notifications = true
+			}
+		case "":
+		default:
+			return target, fmt.Errorf("unknown target option %q", key)
+		}
+	}
+
+	if target.Name == "" {
+		return target, errors.New("target is missing a name")
+	}
+	if !target.Managed && target.DSN == "" {
+		return target, fmt.Errorf("target %s needs either a dsn or managed", target.Name)
+	}
+	_, err = target.Limits()
+	if err != nil {
+		return target, err
+	}
+
+	return target, nil
+}
+
+// ContainerLimits are the resources of a managed target's container and the
+// size of the database's cache, in bytes. Zero means unlimited or default.
+type ContainerLimits struct {
+	CPUs   float64 `json:"cpus"`
+	Memory int64   `json:"memory"`
+	Cache  int64   `json:"cache"`
+}
+
+// Limits parses the container limits of the target.
+func (target Target) Limits() (limits ContainerLimits, err error) {
+	if target.CPUs < 0 {
+		return limits, fmt.Errorf("target %s needs a positive number of CPUs", target.Name)
+	}
+	limits.CPUs = target.CPUs
+
+	for _, size := range []struct {
+		name  string
+		value string
+		bytes *int64
+	}{
+		{"memory", target.Memory, &limits.Memory},
+		{"cache", target.Cache, &limits.Cache},
+	} {
+		if size.value == "" {
+			continue
+		}
+		*size.bytes, err = units.RAMInBytes(size.value)
+		if err != nil || *size.bytes < 0 {
+			return limits, fmt.Errorf("target %s needs a size like 512m for %s, not %q", target.Name, size.name, size.value)
+		}
+	}
+	return limits, nil
+}
+
+func (limits ContainerLimits) String() string {
+	parts := []string{"unlimited CPUs", "unlimited memory", "default cache"}
+	if limits.CPUs > 0 {
+		parts[0] = fmt.Sprintf("%g CPUs", limits.CPUs)
+	}
+	if limits.Memory > 0 {
+		parts[1] = FormatBytes(limits.Memory) + " memory"
+	}
+	if limits.Cache > 0 {
+		parts[2] = FormatBytes(limits.Cache) + " cache"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MongoDatabaseName returns the database to work in on a Mongo target: the
+// configured one, else the one in the DSN, else "test". Isolated targets use
+// it as the prefix of their run database.
+func (target Target) MongoDatabaseName() string {
+	if target.Database != "" {
+		return target.Database
+	}
+
+	if !target.Managed {
+		cs, err := connstring.Parse(target.DSN)
+		if err == nil && cs.Database != "" {
+			return cs.Database
+		}
+	}
+
+	return "test"
+}
+
+// HasVariant reports whether the target runs the given data model.
+func (target Target) HasVariant(variant string) bool {
+	if len(target.Variants) == 0 {
+		return true
+	}
+	for _, v := range target.Variants {
+		if v == variant {
+			return true
+		}
+	}
+	return false
+}
+
+func (target Target) TLSConfig() (config *tls.Config, err error) {
+	if !target.TLS {
+		return nil, nil
+	}
+
+	config = &tls.Config{InsecureSkipVerify: target.TLSInsecure}
+	if target.TLSCAFile != "" {
+		pem, err := os.ReadFile(target.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", target.TLSCAFile)
+		}
+	}
+
+	return config, nil
+}
+
+// TargetList is a repeatable flag holding target specs. Targets given on the
+// command line replace the defaults and the ones from the environment.
+type TargetList struct {
+	Targets []Target
+	set     bool
+}
+
+func (list *TargetList) String() string {
+	if list == nil {
+		return ""
+	}
+	names := make([]string, 0, len(list.Targets))
+	for _, target := range list.Targets {
+		names = append(names, target.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (list *TargetList) Set(spec string) error {
+	target, err := ParseTarget(spec)
+	if err != nil {
+		return err
+	}
+
+	if !list.set {
+		list.Targets, list.set = nil, true
+	}
+	list.Targets = append(list.Targets, target)
+	return nil
+}
+
+// LoadEnv replaces the default targets with the ";" separated specs in the
+// environment variable, unless targets were already given as flags.
+func (list *TargetList) LoadEnv(name string) error {
+	value := os.Getenv(name)
+	if list.set || value == "" {
+		return nil
+	}
+
+	for _, spec := range strings.Split(value, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		err := list.Set(spec)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Use replaces the default targets with the ones from the targets file,
+// unless targets were already given as flags or in the environment.
+func (list *TargetList) Use(targets []Target) {
+	if list.set || len(targets) == 0 {
+		return
+	}
+
+	list.Targets, list.set = targets, true
+}
+
+// runID names the databases created for this run, so that concurrent runs
+// never share data and leftovers of crashed runs can be told apart.
+var runID = fmt.Sprintf("%s_%04x", time.Now().UTC().Format("20060102_150405"), rand.Intn(0x10000))
+
+// isolated reports whether the target gets a database of its own for this run.
+func (target Target) isolated() bool {
+	return !target.Managed && !target.ReuseDatabase
+}
+
+/* POSTGRES */
+
+// PostgresConnector opens connection pools on a Postgres target.
+type PostgresConnector struct {
+	ConnectionString string
+	Database         string
+	TLSConfig        *tls.Config
+
+	// container is set on managed targets, which can be restarted.
+	container testcontainers.Container
+
+	mu    sync.Mutex
+	pools []*pgxpool.Pool
+}
+
+// Open returns a pool whose connections work in the schema. The pool is
+// closed with the target.
+func (connector *PostgresConnector) Open(schema string, minPool int, maxPool int) (pool *pgxpool.Pool, err error) {
+	pool, err = ConnectPostgresPool(connector.ConnectionString, connector.Database, connector.TLSConfig, schema, minPool, maxPool)
+	if err != nil {
+		return nil, err
+	}
+
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	connector.pools = append(connector.pools, pool)
+	return pool, nil
+}
+
+// Release closes a pool opened by the connector before the target is closed,
+// so that a restart no longer waits for it.
+func (connector *PostgresConnector) Release(pool *pgxpool.Pool) {
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	connector.pools = slices.DeleteFunc(connector.pools, func(open *pgxpool.Pool) bool {
+		return open == pool
+	})
+	pool.Close()
+}
+
+// Close closes every pool opened by the connector.
+func (connector *PostgresConnector) Close() {
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	for _, pool := range connector.pools {
+		pool.Close()
+	}
+	connector.pools = nil
+}
+
+// Restart restarts the container of a managed target, which starts Postgres
+// with empty shared buffers, and drops the connections of every pool. The
+// operating system's page cache stays as it was.
+func (connector *PostgresConnector) Restart() (err error) {
+	if connector.container == nil {
+		return errors.New("only managed targets can be restarted")
+	}
+
+	ctx := context.Background()
+	timeout := connectTimeout
+	err = connector.container.Stop(ctx, &timeout)
+	if err != nil {
+		return err
+	}
+	err = connector.container.Start(ctx)
+	if err != nil {
+		return err
+	}
+
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	for _, pool := range connector.pools {
+		pool.Reset()
+	}
+
+	// The port opens before Postgres accepts connections.
+	deadline := time.Now().Add(connectTimeout)
+	for _, pool := range connector.pools {
+		for {
+			err = pool.Ping(ctx)
+			if err == nil || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedHostConfig caps the CPUs and memory of a managed container and binds
+// the container port to a free port on the host, which unlike a port Docker
+// picks survives restarts of the container. The port is only free when it is
+// picked, so containers are started through runOnFreePort.
+func managedHostConfig(containerPort nat.Port, limits ContainerLimits) (testcontainers.CustomizeRequestOption, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+	hostPort := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+
+	return testcontainers.WithHostConfigModifier(func(hostConfig *container.HostConfig) {
+		hostConfig.PortBindings = nat.PortMap{containerPort: {{HostPort: hostPort}}}
+		hostConfig.NanoCPUs = int64(limits.CPUs * 1e9)
+		hostConfig.Memory = limits.Memory
+	}), nil
+}
+
+// portAttempts is how many free ports a managed container is tried on.
+const portAttempts = 5
+
+// runOnFreePort runs a managed container with the host config of
+// managedHostConfig, and again on another free port if something took the
+// one picked before Docker could bind it.
+func runOnFreePort[C testcontainers.Container](containerPort nat.Port, limits ContainerLimits, run func(hostConfig testcontainers.CustomizeRequestOption) (C, error)) (container C, err error) {
+	var hostConfig testcontainers.CustomizeRequestOption
+	for attempt := 1; ; attempt++ {
+		hostConfig, err = managedHostConfig(containerPort, limits)
+		if err != nil {
+			return container, err
+		}
+
+		container, err = run(hostConfig)
+		if err == nil || !isPortTaken(err) || attempt == portAttempts {
+			return container, err
+		}
+		log.Printf("host port taken, retrying: %v", err)
+		testcontainers.TerminateContainer(container)
+	}
+}
+
+// isPortTaken reports whether Docker could not bind a host port because it
+// is in use.
+func isPortTaken(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "port is already allocated") || strings.Contains(message, "address already in use")
+}
+
+// withArgs appends arguments to the command of a container.
+func withArgs(args ...string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) error {
+		req.Cmd = append(req.Cmd, args...)
+		return nil
+	}
+}
+
+// withReplicaSetKeyFile runs a Mongo container with authentication as a
+// single-node replica set, whose members then need a shared key file to
+// authenticate each other. The image's entrypoint hands the files in
+// /data/configdb to the mongodb user, which mongod runs as. Creating the
+// root user restarts mongod once, so the container is only ready after the
+// second "Waiting for connections".
+func withReplicaSetKeyFile(replSetName string, username string, password string) (testcontainers.CustomizeRequestOption, error) {
+	key := make([]byte, 96)
+	_, err := cryptorand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(req *testcontainers.GenericContainerRequest) error {
+		const keyFilePath = "/data/configdb/keyfile"
+		req.Files = append(req.Files, testcontainers.ContainerFile{
+			Reader:            strings.NewReader(base64.StdEncoding.EncodeToString(key)),
+			ContainerFilePath: keyFilePath,
+			FileMode:          0o400,
+		})
+		req.Cmd = append(req.Cmd, "--replSet", replSetName, "--keyFile", keyFilePath)
+		req.WaitingFor = wait.ForAll(
+			wait.ForLog("Waiting for connections").WithOccurrence(2),
+			wait.ForListeningPort("27017/tcp"),
+		)
+
+		mongosh := func(script string) []string {
+			return []string{"mongosh", "--quiet", "--username", username, "--password", password, "--authenticationDatabase", "admin", "--eval", script}
+		}
+		req.LifecycleHooks = append(req.LifecycleHooks, testcontainers.ContainerLifecycleHooks{
+			PostStarts: []testcontainers.ContainerHook{
+				func(ctx context.Context, c testcontainers.Container) error {
+					ip, err := c.ContainerIP(ctx)
+					if err != nil {
+						return err
+					}
+
+					initiate := fmt.Sprintf("try { rs.status() } catch (e) { rs.initiate({ _id: '%s', members: [{ _id: 0, host: '%s:27017' }] }) }", replSetName, ip)
+					err = wait.ForExec(mongosh(initiate)).WithStartupTimeout(time.Minute).WaitUntilReady(ctx, c)
+					if err != nil {
+						return err
+					}
+					return wait.ForExec(mongosh("quit(db.hello().isWritablePrimary ? 0 : 1)")).WithStartupTimeout(time.Minute).WaitUntilReady(ctx, c)
+				},
+			},
+		})
+		return nil
+	}, nil
+}
+
+// appliedLimits reads the CPU and memory limits of a container back from
+// Docker. Remote targets have no container and report none.
+func appliedLimits(ctx context.Context, c testcontainers.Container) (limits ContainerLimits, err error) {
+	if c == nil {
+		return limits, nil
+	}
+	inspect, err := c.Inspect(ctx)
+	if err != nil {
+		return limits, err
+	}
+	if inspect.HostConfig != nil {
+		limits.CPUs = float64(inspect.HostConfig.NanoCPUs) / 1e9
+		limits.Memory = inspect.HostConfig.Memory
+	}
+	return limits, nil
+}
+
+// OpenPostgresTarget starts the container of a managed target and returns a
+// connector for it. The returned function closes the connector's pools, drops
+// the database of an isolated target and stops the container.
+func OpenPostgresTarget(target Target) (connector *PostgresConnector, closeTarget func(), err error) {
+	if target.Missing != nil {
+		return nil, nil, target.Missing
+	}
+
+	connectionString := target.DSN
+	database := target.Database
+	var container testcontainers.Container
+	var dropDatabase func()
+	closeTarget = func() {
+		if connector != nil {
+			connector.Close()
+		}
+		if dropDatabase != nil {
+			dropDatabase()
+		}
+		if container != nil {
+			container.Terminate(context.Background())
+		}
+	}
+
+	if target.Managed {
+		if database == "" {
+			database = "test"
+		}
+
+		println("Starting Postgres container for " + target.Name + "...")
+		limits, err := target.Limits()
+		if err != nil {
+			return nil, nil, err
+		}
+		containerConnectionString, postgresContainer, err := StartPostgres(database, limits)
+		if err != nil {
+			return nil, nil, err
+		}
+		connectionString, container = containerConnectionString, postgresContainer
+	}
+
+	tlsConfig, err := target.TLSConfig()
+	if err != nil {
+		closeTarget()
+		return nil, nil, err
+	}
+
+	if target.isolated() {
+		admin, err := ConnectPostgres(connectionString, database, tlsConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		adminDatabase := admin.Config().Database
+		runDatabase := "dbi_" + runID
+		if adminDatabase != "" {
+			runDatabase = adminDatabase + "_" + runID
+		}
+
+		_, err = admin.Exec(context.Background(), `CREATE DATABASE `+pgx.Identifier{runDatabase}.Sanitize())
+		admin.Close(context.Background())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		println("Using database " + runDatabase + " on " + target.Name)
+		database = runDatabase
+		dropDatabase = func() {
+			admin, err := ConnectPostgres(connectionString, adminDatabase, tlsConfig)
+			if err != nil {
+				log.Printf("failed to drop database %s on %s: %v", runDatabase, target.Name, err)
+				return
+			}
+			defer admin.Close(context.Background())
+
+			_, err = admin.Exec(context.Background(), `DROP DATABASE IF EXISTS `+pgx.Identifier{runDatabase}.Sanitize()+` WITH (FORCE)`)
+			if err != nil {
+				log.Printf("failed to drop database %s on %s: %v", runDatabase, target.Name, err)
+			}
+		}
+	}
+
+	connector = &PostgresConnector{
+		ConnectionString: connectionString,
+		Database:         database,
+		TLSConfig:        tlsConfig,
+		container:        container,
+	}
+	return connector, closeTarget, nil
+}
+
+// Limits returns the limits the target runs with, reading shared_buffers
+// through the first pool the connector opened.
+func (connector *PostgresConnector) Limits() (limits ContainerLimits, err error) {
+	ctx := context.Background()
+	limits, err = appliedLimits(ctx, connector.container)
+	if err != nil {
+		return limits, err
+	}
+
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	if len(connector.pools) == 0 {
+		return limits, nil
+	}
+	err = connector.pools[0].QueryRow(ctx, `SELECT pg_size_bytes(current_setting('shared_buffers'));`).Scan(&limits.Cache)
+	return limits, err
+}
+
+/* MONGODB */
+
+// MongoConnector opens clients on a Mongo target.
+type MongoConnector struct {
+	ConnectionString string
+	ServerAPI        bool
+	TLSConfig        *tls.Config
+
+	// container is set on managed targets.
+	container testcontainers.Container
+
+	mu      sync.Mutex
+	clients []*mongo.Client
+}
+
+// Open returns a client with a connection pool of the given size. The client
+// is disconnected with the target.
+func (connector *MongoConnector) Open(minPool int, maxPool int) (client *mongo.Client, err error) {
+	client, err = ConnectMongoDB(connector.ConnectionString, connector.ServerAPI, connector.TLSConfig, minPool, maxPool)
+	if err != nil {
+		return nil, err
+	}
+
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	connector.clients = append(connector.clients, client)
+	return client, nil
+}
+
+// Close disconnects every client opened by the connector.
+func (connector *MongoConnector) Close() {
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	for _, client := range connector.clients {
+		client.Disconnect(context.Background())
+	}
+	connector.clients = nil
+}
+
+// Limits returns the limits the target runs with, reading the size of the
+// WiredTiger cache through the first client the connector opened.
+func (connector *MongoConnector) Limits() (limits ContainerLimits, err error) {
+	ctx := context.Background()
+	limits, err = appliedLimits(ctx, connector.container)
+	if err != nil {
+		return limits, err
+	}
+
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	if len(connector.clients) == 0 {
+		return limits, nil
+	}
+	limits.Cache, _, err = mongoCache(ctx, connector.clients[0].Database("admin"))
+	return limits, err
+}
+
+// OpenMongoTarget starts the container of a managed target and connects to
+// it. The returned connector opens further clients on the target. The
+// returned function drops the database of an isolated target, disconnects
+// all clients and stops the container.
+func OpenMongoTarget(target Target) (database *mongo.Database, connector *MongoConnector, closeTarget func(), err error) {
+	if target.Missing != nil {
+		return nil, nil, nil, target.Missing
+	}
+
+	connectionString := target.DSN
+	var container testcontainers.Container
+	closeTarget = func() {
+		if connector != nil {
+			if database != nil && target.isolated() {
+				dropMongoDatabases(target, database)
+			}
+			connector.Close()
+		}
+		if container != nil {
+			testcontainers.TerminateContainer(container)
+		}
+	}
+
+	if target.Managed {
+		println("Starting MongoDB container for " + target.Name + "...")
+		limits, err := target.Limits()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		containerConnectionString, mongoContainer, err := StartMongoDB(target.ReplicaSet, limits)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		connectionString, container = containerConnectionString, mongoContainer
+	}
+
+	tlsConfig, err := target.TLSConfig()
+	if err != nil {
+		closeTarget()
+		return nil, nil, nil, err
+	}
+
+	connector = &MongoConnector{
+		ConnectionString: connectionString,
+		ServerAPI:        target.ServerAPI,
+		TLSConfig:        tlsConfig,
+		container:        container,
+	}
+	client, err := connector.Open(target.MinPool, target.MaxPool)
+	if err != nil {
+		closeTarget()
+		return nil, nil, nil, err
+	}
+
+	name := target.MongoDatabaseName()
+	if target.isolated() {
+		name = name + "_" + runID
+		println("Using database " + name + " on " + target.Name)
+	}
+
+	database = client.Database(name)
+	return database, connector, closeTarget, nil
+}
+
+// dropMongoDatabases drops the run database of an isolated target together
+// with the databases of its variants, which share its name as a prefix.
+func dropMongoDatabases(target Target, database *mongo.Database) {
+	ctx := context.Background()
+	names, err := database.Client().ListDatabaseNames(ctx, bson.M{"name": bson.M{"$regex": "^" + regexp.QuoteMeta(database.Name())}})
+	if err != nil {
+		log.Printf("failed to list databases on %s: %v", target.Name, err)
+		return
+	}
+
+	for _, name := range names {
+		err = database.Client().Database(name).Drop(ctx)
+		if err != nil {
+			log.Printf("failed to drop database %s on %s: %v", name, target.Name, err)
+		}
+	}
+}
+
+/* EXIT */
+
+var (
+	atExitMu sync.Mutex
+	atExit   []func()
+)
+
+// AtExit registers fn to run when the benchmark exits, including on a panic
+// or an interrupt. Functions run in reverse order of registration.
+func AtExit(fn func()) {
+	atExitMu.Lock()
+	defer atExitMu.Unlock()
+	atExit = append(atExit, fn)
+}
+
+// RunAtExit runs and forgets every registered function.
+func RunAtExit() {
+	atExitMu.Lock()
+	fns := atExit
+	atExit = nil
+	atExitMu.Unlock()
+
+	for i := len(fns) - 1; i >= 0; i-- {
+		fns[i]()
+	}
+}
+
+// HandleInterrupts runs the exit functions when the process is interrupted,
+// which skips deferred calls.
+func HandleInterrupts() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		println("Interrupted, cleaning up...")
+		RunAtExit()
+		os.Exit(1)
+	}()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestRestartAfterReconnect restarts a managed target after the pool sweep's
+// pool is closed, which the restart must no longer wait for.
+func TestRestartAfterReconnect(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a Postgres container")
+	}
+	skipWithoutDocker(t)
+
+	connector, closeTarget, err := OpenPostgresTarget(Target{Name: "Restart", Managed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeTarget()
+
+	pool, err := connector.Open("public", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variant := reconnectPostgres(PostgresVariant("Restart", pool), connector, "public")
+
+	_, closePool, err := variant.Reconnect(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closePool()
+
+	err = connector.Restart()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+/* UPSERTS */
+
+// Upserts are keyed on the project identifier, which every model indexes as
+// unique. A project whose identifier exists gets its name, invite code and
+// sprint duration updated, any other one is inserted together with its owner
+// and sprints, so that running the same batch twice leaves the same data.
+
+// UpsertFunc upserts the projects. It returns the number of projects it
+// inserted, the others were updated.
+type UpsertFunc func(projects []models.Project) (duration time.Duration, inserted int64, err error)
+
+// UpsertBatch returns as many projects as there are existing ones, of which
+// the given percentage are existing projects with new names, invite codes
+// and sprint durations and the rest are new projects.
+func UpsertBatch(existing []models.Project, ratio int) []models.Project {
+	updates := len(existing) * ratio / 100
+	batch := make([]models.Project, 0, len(existing))
+	for _, project := range existing[:updates] {
+		project.Name = RandomString(10)
+		project.InviteCode = RandomString(128)
+		project.SprintDuration = rand.Intn(100) + 1
+		batch = append(batch, project)
+	}
+	return append(batch, GenerateProjects(len(existing)-updates)...)
+}
+
+// SweepUpserts fills every variant with projects and upserts a batch of the
+// same size into it, for each percentage of existing identifiers. It returns
+// the table rows, the chart series keyed by variant name and the variants
+// whose state differs from the first one's after an upsert.
+func SweepUpserts(variants []Variant, size int, ratios []int) (rows [][]string, data map[string][]opts.LineData, mismatches []Mismatch, err error) {
+	data = make(map[string][]opts.LineData)
+	for _, ratio := range ratios {
+		projects := GenerateProjects(size)
+		err = FillVariants(variants, projects)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		batch := UpsertBatch(projects, ratio)
+		row := []string{fmt.Sprintf("%d%%", ratio)}
+		for _, variant := range variants {
+			if variant.Upsert == nil {
+				row = append(row, "-")
+				continue
+			}
+
+			duration, inserted, err := variant.Upsert(batch)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("upserting with %d%% existing identifiers on %s: %w", ratio, variant.Name, err)
+			}
+
+			row = append(row, fmt.Sprintf("%s (%d inserted)", duration, inserted))
+			data[variant.Name] = append(data[variant.Name], opts.LineData{Value: float64(duration.Microseconds()) / 1000})
+		}
+
+		states, err := States(variants)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, mismatch := range VerifyStates(size, fmt.Sprintf("Upsert with %d%% existing", ratio), variants, states) {
+			log.Printf("%s differs from %s after upserting with %d%% existing identifiers: %s instead of %s", mismatch.Variant, variants[0].Name, ratio, mismatch.Actual, mismatch.Expected)
+			mismatches = append(mismatches, mismatch)
+			for i, variant := range variants {
+				if variant.Name == mismatch.Variant {
+					row[1+i] += " (mismatch)"
+				}
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, data, mismatches, nil
+}
+
+/* POSTGRES */
+
+// UpsertPostgres upserts every project with a single statement, which only
+// inserts the owner when the identifier is new. xmax is zero for a row that
+// was inserted rather than updated.
+func UpsertPostgres(conn *pgxpool.Pool, projects []models.Project) (duration time.Duration, inserted int64, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	// Owners shared by several new projects are stored once.
+	userIds := make(map[string]int)
+	for _, project := range projects {
+		var knownUserId *int
+		if userId, ok := userIds[project.Owner.Username]; ok {
+			knownUserId = &userId
+		}
+
+		var projectId, ownerId int
+		var isInsert bool
+		err = conn.QueryRow(ctx, `
+			WITH existing AS (
+				SELECT owner_id FROM projects WHERE identifier = $2
+			), owner AS (
+				INSERT INTO users (username, first_name, last_name)
+				SELECT $5, $6, $7 WHERE $8::INT IS NULL AND NOT EXISTS (SELECT 1 FROM existing)
+				RETURNING id
+			)
+			INSERT INTO projects (name, identifier, invite_code, sprint_duration, owner_id)
+			SELECT $1, $2, $3, $4, COALESCE($8::INT, (SELECT id FROM owner), (SELECT owner_id FROM existing))
+			ON CONFLICT (identifier) DO UPDATE SET name = EXCLUDED.name, invite_code = EXCLUDED.invite_code, sprint_duration = EXCLUDED.sprint_duration
+			RETURNING id, owner_id, xmax = 0;`,
+			project.Name, project.Identifier, project.InviteCode, project.SprintDuration,
+			project.Owner.Username, project.Owner.FirstName, project.Owner.LastName, knownUserId).Scan(&projectId, &ownerId, &isInsert)
+		if err != nil {
+			return time.Since(now), inserted, err
+		}
+		if !isInsert {
+			continue
+		}
+
+		inserted++
+		userIds[project.Owner.Username] = ownerId
+		for _, sprint := range project.Sprints {
+			_, err = conn.Exec(ctx,
+				`INSERT INTO sprints (name, project_id, start_date, end_date) VALUES ($1, $2, $3, $4);`,
+				sprint.Name, projectId, sprint.StartDate, sprint.EndDate)
+			if err != nil {
+				return time.Since(now), inserted, err
+			}
+		}
+	}
+	return time.Since(now), inserted, nil
+}
+
+/* SQLITE */
+
+// UpsertSqlite looks the identifier up first, since SQLite cannot insert the
+// owner from within the upsert, and inserts the owner only for new projects.
+func UpsertSqlite(conn *sql.DB, projects []models.Project) (duration time.Duration, inserted int64, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	// Owners shared by several new projects are stored once.
+	userIds := make(map[string]int64)
+	for _, project := range projects {
+		var ownerId int64
+		err = conn.QueryRowContext(ctx, `SELECT owner_id FROM projects WHERE identifier = ?;`, project.Identifier).Scan(&ownerId)
+		isInsert := errors.Is(err, sql.ErrNoRows)
+		if err != nil && !isInsert {
+			return time.Since(now), inserted, err
+		}
+
+		if isInsert {
+			var ok bool
+			ownerId, ok = userIds[project.Owner.Username]
+			if !ok {
+				result, err := conn.ExecContext(ctx,
+					`INSERT INTO users (username, first_name, last_name) VALUES (?, ?, ?);`,
+					project.Owner.Username, project.Owner.FirstName, project.Owner.LastName)
+				if err != nil {
+					return time.Since(now), inserted, err
+				}
+
+				ownerId, err = result.LastInsertId()
+				if err != nil {
+					return time.Since(now), inserted, err
+				}
+				userIds[project.Owner.Username] = ownerId
+			}
+		}
+
+		result, err := conn.ExecContext(ctx,
+			`INSERT INTO projects (name, identifier, invite_code, sprint_duration, owner_id) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (identifier) DO UPDATE SET name = excluded.name, invite_code = excluded.invite_code, sprint_duration = excluded.sprint_duration;`,
+			project.Name, project.Identifier, project.InviteCode, project.SprintDuration, ownerId)
+		if err != nil {
+			return time.Since(now), inserted, err
+		}
+		if !isInsert {
+			continue
+		}
+
+		projectId, err := result.LastInsertId()
+		if err != nil {
+			return time.Since(now), inserted, err
+		}
+
+		inserted++
+		for _, sprint := range project.Sprints {
+			_, err = conn.ExecContext(ctx,
+				`INSERT INTO sprints (name, project_id, start_date, end_date) VALUES (?, ?, ?, ?);`,
+				sprint.Name, projectId, sprint.StartDate, sprint.EndDate)
+			if err != nil {
+				return time.Since(now), inserted, err
+			}
+		}
+	}
+	return time.Since(now), inserted, nil
+}
+
+/* MONGODB */
+
+// IndexMongoIdentifiers makes the project identifiers of a collection unique,
+// which upserts look projects up by.
+func IndexMongoIdentifiers(database *mongo.Database, collection string) (err error) {
+	_, err = database.Collection(collection).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "identifier", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// UpsertMongo upserts all embedded projects with one bulk write. The owner
+// and sprints are only set when the project is inserted.
+func UpsertMongo(database *mongo.Database, collection string, projects []models.Project) (duration time.Duration, inserted int64, err error) {
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(projects))
+	for _, project := range projects {
+		sprints := bson.A{}
+		for _, sprint := range project.Sprints {
+			sprints = append(sprints, bson.M{
+				"name":       sprint.Name,
+				"start_date": sprint.StartDate,
+				"end_date":   sprint.EndDate,
+			})
+		}
+
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"identifier": project.Identifier}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					"name":            project.Name,
+					"invite_code":     project.InviteCode,
+					"sprint_duration": project.SprintDuration,
+				},
+				"$setOnInsert": bson.M{
+					"owner": bson.M{
+						"username":   project.Owner.Username,
+						"first_name": project.Owner.FirstName,
+						"last_name":  project.Owner.LastName,
+					},
+					"sprints": sprints,
+				},
+			}).
+			SetUpsert(true))
+	}
+
+	if len(writes) == 0 {
+		return time.Since(now), 0, nil
+	}
+	result, err := database.Collection(collection).BulkWrite(context.Background(), writes)
+	if err != nil {
+		return time.Since(now), 0, err
+	}
+	return time.Since(now), result.UpsertedCount, nil
+}
+
+// UpsertMongoWithReferencing upserts the projects with one bulk write that
+// points new projects at owner ids chosen up front. Only the owners and
+// sprints of the projects the bulk write inserted are inserted afterwards.
+func UpsertMongoWithReferencing(database *mongo.Database, projects []models.Project) (duration time.Duration, inserted int64, err error) {
+	now := time.Now()
+	ctx := context.Background()
+	// Owners shared by several new projects are stored once.
+	ownerIds := make(map[string]primitive.ObjectID)
+	writes := make([]mongo.WriteModel, 0, len(projects))
+	for _, project := range projects {
+		ownerId, ok := ownerIds[project.Owner.Username]
+		if !ok {
+			ownerId = primitive.NewObjectID()
+			ownerIds[project.Owner.Username] = ownerId
+		}
+
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"identifier": project.Identifier}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					"name":            project.Name,
+					"invite_code":     project.InviteCode,
+					"sprint_duration": project.SprintDuration,
+				},
+				"$setOnInsert": bson.M{"owner": ownerId},
+			}).
+			SetUpsert(true))
+	}
+
+	if len(writes) == 0 {
+		return time.Since(now), 0, nil
+	}
+	result, err := database.Collection("projects").BulkWrite(ctx, writes)
+	if err != nil {
+		return time.Since(now), 0, err
+	}
+
+	var users, sprints []any
+	insertedOwners := make(map[string]bool)
+	for i, projectId := range result.UpsertedIDs {
+		project := projects[i]
+		if !insertedOwners[project.Owner.Username] {
+			insertedOwners[project.Owner.Username] = true
+			users = append(users, bson.M{
+				"_id":        ownerIds[project.Owner.Username],
+				"username":   project.Owner.Username,
+				"first_name": project.Owner.FirstName,
+				"last_name":  project.Owner.LastName,
+			})
+		}
+
+		for _, sprint := range project.Sprints {
+			sprints = append(sprints, bson.M{
+				"name":       sprint.Name,
+				"project":    projectId,
+				"start_date": sprint.StartDate,
+				"end_date":   sprint.EndDate,
+			})
+		}
+	}
+
+	if len(users) > 0 {
+		_, err = database.Collection("users").InsertMany(ctx, users)
+		if err != nil {
+			return time.Since(now), result.UpsertedCount, err
+		}
+	}
+	if len(sprints) > 0 {
+		_, err = database.Collection("sprints").InsertMany(ctx, sprints)
+		if err != nil {
+			return time.Since(now), result.UpsertedCount, err
+		}
+	}
+	return time.Since(now), result.UpsertedCount, nil
+}
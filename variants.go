@@ -0,0 +1,608 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/fabiansefranek/dbi-perf-tests/models"
+)
+
+// Measurement is the outcome of running one operation on one variant. Rows
+// and Bytes count what a read returned and stay zero for writes.
+type Measurement struct {
+	Duration time.Duration
+	Rows     int64
+	Bytes    int64
+}
+
+// OperationFunc runs one measured operation. Operations that do not insert
+// data ignore the projects.
+type OperationFunc func(projects []models.Project) (Measurement, error)
+
+// FilterFunc runs a filtered read for the projects with a sprint duration
+// above the threshold.
+type FilterFunc func(threshold int) (Measurement, error)
+
+// defaultThreshold is the filter the results table uses, which selects about
+// half of the projects.
+const defaultThreshold = 50
+
+// ThresholdFor returns the threshold at which a filter selects the given
+// percentage of projects. Sprint durations are spread evenly from 1 to 100.
+func ThresholdFor(selectivity int) int {
+	return 100 - selectivity
+}
+
+// withThreshold runs a filtered read as an operation of the results table.
+func withThreshold(filter FilterFunc, threshold int) OperationFunc {
+	return func(projects []models.Project) (Measurement, error) {
+		return filter(threshold)
+	}
+}
+
+// timed wraps the duration of a write as a Measurement.
+func timed(duration time.Duration, err error) (Measurement, error) {
+	return Measurement{Duration: duration}, err
+}
+
+// Variant is one column of the results table: a database setup together
+// with its implementation of each operation. Operations a variant does not
+// support are left out of the map and show up as "-".
+type Variant struct {
+	Name string
+	// Reset empties the variant's namespace before every size.
+	Reset func() error
+	// Count returns the number of records in the variant's namespace.
+	Count func() (int64, error)
+	// State summarizes the variant's data for the cross-variant check that
+	// follows every operation.
+	State      func() (State, error)
+	Operations map[string]OperationFunc
+	// Filters holds the filtered reads for the selectivity sweep, keyed by
+	// operation name.
+	Filters map[string]FilterFunc
+	// Searches are the ways the variant can search names, in the order the
+	// search benchmark runs them.
+	Searches []SearchMethod
+	// Sprints changes and reads the sprints of a single project, for the
+	// sprint operations and the growth sweep.
+	Sprints SprintFuncs
+	// Rename renames a user for the rename operation and sweep.
+	Rename RenameFunc
+	// Subscribe listens for added sprints for the notification benchmark.
+	// Variants that cannot notify leave it nil.
+	Subscribe SubscribeFunc
+	// Upsert upserts projects by identifier for the upsert sweep.
+	Upsert UpsertFunc
+	// Evolution adds an attribute to the projects for the schema evolution
+	// sweep.
+	Evolution EvolutionFuncs
+	// Storage measures the space the variant's data takes up after the
+	// inserts of every size.
+	Storage StorageFunc
+	// Evict empties the caches the variant's reads are served from, for the
+	// cold reads of the cache sweep. Variants that cannot leave it nil.
+	Evict func() error
+	// Sample samples the server the variant runs on while the operations of
+	// the size runs are measured. Variants without a server, or whose server
+	// cannot be sampled, leave it nil.
+	Sample SampleFunc
+	// Profile turns on what makes the samples of Sample say how long the
+	// server took, for servers where that costs the other measurements.
+	Profile ProfileFunc
+	// InProcess is set on variants whose database runs in the benchmark's own
+	// process, so that all of their time is the client's.
+	InProcess bool
+	// Reconnect builds the variant again on a client of its own with a
+	// connection pool of the given size, for the pool sweep. The returned
+	// function closes that client. Variants without a pool leave it nil.
+	Reconnect func(poolSize int) (Variant, func(), error)
+}
+
+// CountRecords counts the records of every variant, keyed by variant name.
+func CountRecords(variants []Variant) (counts map[string]int64, err error) {
+	counts = make(map[string]int64)
+	for _, variant := range variants {
+		counts[variant.Name], err = variant.Count()
+		if err != nil {
+			return nil, fmt.Errorf("counting records of %s: %w", variant.Name, err)
+		}
+	}
+	return counts, nil
+}
+
+// ResetVariants empties every variant and verifies that all of them start
+// from the same empty state.
+func ResetVariants(variants []Variant) (err error) {
+	for _, variant := range variants {
+		err = variant.Reset()
+		if err != nil {
+			return fmt.Errorf("resetting %s: %w", variant.Name, err)
+		}
+	}
+
+	counts, err := CountRecords(variants)
+	if err != nil {
+		return err
+	}
+
+	for _, variant := range variants {
+		if counts[variant.Name] != 0 {
+			return fmt.Errorf("%s still holds %d records after the reset", variant.Name, counts[variant.Name])
+		}
+	}
+	return nil
+}
+
+// FillVariants empties every variant and inserts the projects into it, for
+// the measurements that follow the size runs.
+func FillVariants(variants []Variant, projects []models.Project) (err error) {
+	err = ResetVariants(variants)
+	if err != nil {
+		return err
+	}
+
+	for _, variant := range variants {
+		_, err = variant.Operations["Insert"](projects)
+		if err != nil {
+			return fmt.Errorf("inserting into %s: %w", variant.Name, err)
+		}
+	}
+	return nil
+}
+
+// SchemaName turns a variant name into the name of its Postgres schema,
+// for example "Postgres (Index)" into "postgres_index".
+func SchemaName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// Operation is one row of the results table.
+type Operation struct {
+	Name string
+	// Read operations return data, so every variant has to return the same
+	// number of records for them.
+	Read bool
+	// Chart is the title of the operation's line chart in charts.html, if
+	// it gets one.
+	Chart string
+}
+
+// operations lists the rows of the results table in the order they are run.
+// Every variant implements an operation with the same effect on its data:
+//
+//   - Insert stores the generated projects with their owners and sprints.
+//   - Find reads every sprint together with its project and owner.
+//   - Find with filter does the same for projects with a sprint duration
+//     above 50, which the selectivity sweep varies.
+//   - Find with filter and projection returns the owner's username, the
+//     project name and the sprint name for every sprint of those projects,
+//     and the sort variant orders them by the sprint's start date.
+//   - Find with aggregation counts the projects of every owner.
+//   - The page operations read 50 sprints ordered by start date and id from
+//     the start, the middle and the end, either skipping the sprints before
+//     the page or starting after the last sprint of the previous page.
+//   - Update moves the start date of every sprint a day later.
+//   - Add sprint appends the next sprint to the fourth project, Update
+//     sprint moves the end date of that project's first sprint a day later
+//     and Remove sprint removes the added sprint again, all by name.
+//   - Rename owner renames the owner of the fifth project in every project
+//     that refers to or holds a copy of them.
+//   - Delete project removes the first project with its sprints and its
+//     owner, and Delete project with cascade does the same for the second
+//     one, leaving the sprints to the foreign key where the model has one.
+//   - Delete user removes the owner of the third project with all of the
+//     projects they own.
+//   - Delete by filter removes the projects with a sprint duration from 41
+//     to 50 like Delete project does.
+//   - Delete removes all projects together with their sprints and owners.
+var operations = []Operation{
+	{Name: "Insert", Chart: "Insert"},
+	{Name: "Find", Read: true},
+	{Name: "Find with filter", Read: true, Chart: "Find (With Filter)"},
+	{Name: "Find with filter and projection", Read: true},
+	{Name: "Find with filter and projection and sort", Read: true},
+	{Name: "Find with aggregation", Read: true},
+	{Name: "First page with offset", Read: true},
+	{Name: "Middle page with offset", Read: true},
+	{Name: "Last page with offset", Read: true, Chart: "Last Page (Offset)"},
+	{Name: "First page with keyset", Read: true},
+	{Name: "Middle page with keyset", Read: true},
+	{Name: "Last page with keyset", Read: true, Chart: "Last Page (Keyset)"},
+	{Name: "Update", Chart: "Update"},
+	{Name: "Add sprint"},
+	{Name: "Update sprint"},
+	{Name: "Remove sprint"},
+	{Name: "Rename owner"},
+	{Name: "Delete project"},
+	{Name: "Delete project with cascade"},
+	{Name: "Delete user"},
+	{Name: "Delete by filter", Chart: "Delete (By Filter)"},
+	{Name: "Delete", Chart: "Delete"},
+}
+
+func PostgresVariant(name string, conn *pgxpool.Pool) Variant {
+	sprints := SprintFuncs{
+		Add: func(identifier string, sprint models.Sprint) (time.Duration, error) {
+			return InsertPostgresSprint(conn, identifier, sprint)
+		},
+		Update: func(identifier string, name string) (time.Duration, error) {
+			return UpdatePostgresSprint(conn, identifier, name)
+		},
+		Remove: func(identifier string, name string) (time.Duration, error) {
+			return DeletePostgresSprint(conn, identifier, name)
+		},
+		Read: func(identifier string) (Measurement, error) {
+			return ReadPostgresProject(conn, identifier)
+		},
+	}
+
+	filters := map[string]FilterFunc{
+		"Find with filter": func(threshold int) (Measurement, error) {
+			return FindPostgresWithFilter(conn, threshold)
+		},
+		"Find with filter and projection": func(threshold int) (Measurement, error) {
+			return FindPostgresWithFilterAndProjection(conn, threshold)
+		},
+		"Find with filter and projection and sort": func(threshold int) (Measurement, error) {
+			return FindPostgresWithFilterAndProjectionAndSort(conn, threshold)
+		},
+	}
+
+	rename := func(username string, newUsername string) (time.Duration, int64, error) {
+		return RenamePostgresUser(conn, username, newUsername)
+	}
+
+	return Variant{
+		Name: name,
+		Reset: func() error {
+			return ResetPostgres(conn)
+		},
+		Count: func() (int64, error) {
+			return CountPostgres(conn)
+		},
+		State: func() (State, error) {
+			return StatePostgres(conn)
+		},
+		Operations: withPages(withSprints(map[string]OperationFunc{
+			"Insert": func(projects []models.Project) (Measurement, error) {
+				return timed(InsertPostgres(conn, projects))
+			},
+			"Find": func(projects []models.Project) (Measurement, error) {
+				return FindPostgres(conn)
+			},
+			"Find with filter":                         withThreshold(filters["Find with filter"], defaultThreshold),
+			"Find with filter and projection":          withThreshold(filters["Find with filter and projection"], defaultThreshold),
+			"Find with filter and projection and sort": withThreshold(filters["Find with filter and projection and sort"], defaultThreshold),
+			"Find with aggregation": func(projects []models.Project) (Measurement, error) {
+				return FindPostgresWithAggregation(conn)
+			},
+			"Update": func(projects []models.Project) (Measurement, error) {
+				return timed(UpdatePostgres(conn))
+			},
+			"Rename owner": withRename(rename),
+			"Delete project": func(projects []models.Project) (Measurement, error) {
+				return timed(DeletePostgresProjects(conn, false, `identifier = $1`, projects[0].Identifier))
+			},
+			"Delete project with cascade": func(projects []models.Project) (Measurement, error) {
+				return timed(DeletePostgresProjects(conn, true, `identifier = $1`, projects[1].Identifier))
+			},
+			"Delete by filter": func(projects []models.Project) (Measurement, error) {
+				return timed(DeletePostgresProjects(conn, false, `sprint_duration BETWEEN $1 AND $2`, deleteDurations[0], deleteDurations[1]))
+			},
+			"Delete user": func(projects []models.Project) (Measurement, error) {
+				return timed(DeletePostgresUser(conn, projects[2].Owner.Username))
+			},
+			"Delete": func(projects []models.Project) (Measurement, error) {
+				return timed(DeletePostgres(conn))
+			},
+		}, sprints), func() (int64, error) {
+			return CountPostgresSprints(conn)
+		}, func(page int64, keyset bool) (Measurement, error) {
+			return PagePostgres(conn, page, keyset)
+		}),
+		Filters:   filters,
+		Searches:  PostgresSearches(conn),
+		Rename:    rename,
+		Sprints:   sprints,
+		Subscribe: SubscribePostgres(conn),
+		Upsert: func(projects []models.Project) (time.Duration, int64, error) {
+			return UpsertPostgres(conn, projects)
+		},
+		Evolution: EvolvePostgres(conn),
+		Storage:   StoragePostgres(conn),
+	}
+}
+
+func MongoVariant(name string, database *mongo.Database, collection string) Variant {
+	sprints := SprintFuncs{
+		Add: func(identifier string, sprint models.Sprint) (time.Duration, error) {
+			return PushMongoSprint(database, collection, identifier, sprint)
+		},
+		Update: func(identifier string, name string) (time.Duration, error) {
+			return UpdateMongoSprint(database, collection, identifier, name)
+		},
+		Remove: func(identifier string, name string) (time.Duration, error) {
+			return PullMongoSprint(database, collection, identifier, name)
+		},
+		Read: func(identifier string) (Measurement, error) {
+			return ReadMongoProject(database, collection, identifier)
+		},
+	}
+
+	filters := map[string]FilterFunc{
+		"Find with filter": func(threshold int) (Measurement, error) {
+			return FindMongoWithFilter(database, collection, threshold)
+		},
+		"Find with filter and projection": func(threshold int) (Measurement, error) {
+			return FindMongoWithFilterAndProjection(database, collection, threshold)
+		},
+		"Find with filter and projection and sort": func(threshold int) (Measurement, error) {
+			return FindMongoWithFilterAndProjectionAndSort(database, collection, threshold)
+		},
+	}
+
+	rename := func(username string, newUsername string) (time.Duration, int64, error) {
+		return RenameMongoOwner(database, collection, username, newUsername)
+	}
+
+	return Variant{
+		Name: name,
+		Reset: func() error {
+			return ResetMongo(database, collection)
+		},
+		Count: func() (int64, error) {
+			return CountMongo(database, collection)
+		},
+		State: func() (State, error) {
+			return StateMongo(database, collection)
+		},
+		Operations: withPages(withSprints(map[string]OperationFunc{
+			"Insert": func(projects []models.Project) (Measurement, error) {
+				return timed(InsertMongo(database, projects, collection))
+			},
+			"Find": func(projects []models.Project) (Measurement, error) {
+				return FindMongo(database, collection)
+			},
+			"Find with filter":                         withThreshold(filters["Find with filter"], defaultThreshold),
+			"Find with filter and projection":          withThreshold(filters["Find with filter and projection"], defaultThreshold),
+			"Find with filter and projection and sort": withThreshold(filters["Find with filter and projection and sort"], defaultThreshold),
+			"Find with aggregation": func(projects []models.Project) (Measurement, error) {
+				return FindMongoWithAggregation(database, collection)
+			},
+			"Update": func(projects []models.Project) (Measurement, error) {
+				return timed(UpdateMongo(database, collection))
+			},
+			"Rename owner": withRename(rename),
+			// Removing a document takes its embedded sprints along, which is
+			// as close to a cascade as the model gets.
+			"Delete project": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoWhere(database, collection, bson.M{"identifier": projects[0].Identifier}))
+			},
+			"Delete project with cascade": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoWhere(database, collection, bson.M{"identifier": projects[1].Identifier}))
+			},
+			"Delete by filter": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoWhere(database, collection, bson.M{"sprint_duration": bson.M{"$gte": deleteDurations[0], "$lte": deleteDurations[1]}}))
+			},
+			"Delete user": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoWhere(database, collection, bson.M{"owner.username": projects[2].Owner.Username}))
+			},
+			"Delete": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongo(database, collection))
+			},
+		}, sprints), func() (int64, error) {
+			return CountMongoSprints(database, collection)
+		}, func(page int64, keyset bool) (Measurement, error) {
+			return PageMongo(database, collection, page, keyset)
+		}),
+		Filters:   filters,
+		Searches:  MongoSearches(database, collection),
+		Rename:    rename,
+		Sprints:   sprints,
+		Subscribe: SubscribeMongo(database, collection),
+		Upsert: func(projects []models.Project) (time.Duration, int64, error) {
+			return UpsertMongo(database, collection, projects)
+		},
+		Evolution: EvolveMongo(database, collection),
+		Storage:   StorageMongo(database, collection),
+		Evict: func() error {
+			return EvictMongo(database)
+		},
+	}
+}
+
+func MongoReferencingVariant(name string, database *mongo.Database) Variant {
+	sprints := SprintFuncs{
+		Add: func(identifier string, sprint models.Sprint) (time.Duration, error) {
+			return InsertMongoSprintWithReferencing(database, identifier, sprint)
+		},
+		Update: func(identifier string, name string) (time.Duration, error) {
+			return UpdateMongoSprintWithReferencing(database, identifier, name)
+		},
+		Remove: func(identifier string, name string) (time.Duration, error) {
+			return DeleteMongoSprintWithReferencing(database, identifier, name)
+		},
+		Read: func(identifier string) (Measurement, error) {
+			return ReadMongoProjectWithReferencing(database, identifier)
+		},
+	}
+
+	filters := map[string]FilterFunc{
+		"Find with filter": func(threshold int) (Measurement, error) {
+			return FindMongoWithReferencing(database, bson.M{"sprint_duration": bson.M{"$gt": threshold}})
+		},
+	}
+
+	rename := func(username string, newUsername string) (time.Duration, int64, error) {
+		return RenameMongoUserWithReferencing(database, username, newUsername)
+	}
+
+	return Variant{
+		Name: name,
+		Reset: func() error {
+			return ResetMongo(database, "users", "projects", "sprints")
+		},
+		Count: func() (int64, error) {
+			return CountMongo(database, "users", "projects", "sprints")
+		},
+		State: func() (State, error) {
+			return StateMongoWithReferencing(database)
+		},
+		Operations: withPages(withSprints(map[string]OperationFunc{
+			"Insert": func(projects []models.Project) (Measurement, error) {
+				return timed(InsertMongoWithReferencing(database, projects))
+			},
+			"Find": func(projects []models.Project) (Measurement, error) {
+				return FindMongoWithReferencing(database, bson.M{})
+			},
+			"Find with filter": withThreshold(filters["Find with filter"], defaultThreshold),
+			"Update": func(projects []models.Project) (Measurement, error) {
+				return timed(UpdateMongoWithReferencing(database))
+			},
+			"Rename owner": withRename(rename),
+			// Nothing cascades between collections, so both project deletes
+			// clean up explicitly.
+			"Delete project": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoProjectsWithReferencing(database, bson.M{"identifier": projects[0].Identifier}))
+			},
+			"Delete project with cascade": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoProjectsWithReferencing(database, bson.M{"identifier": projects[1].Identifier}))
+			},
+			"Delete by filter": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoProjectsWithReferencing(database, bson.M{"sprint_duration": bson.M{"$gte": deleteDurations[0], "$lte": deleteDurations[1]}}))
+			},
+			"Delete user": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoUserWithReferencing(database, projects[2].Owner.Username))
+			},
+			"Delete": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteMongoWithReferencing(database))
+			},
+		}, sprints), func() (int64, error) {
+			return CountMongoSprintsWithReferencing(database)
+		}, func(page int64, keyset bool) (Measurement, error) {
+			return PageMongoWithReferencing(database, page, keyset)
+		}),
+		Filters:   filters,
+		Searches:  MongoSearchesWithReferencing(database),
+		Rename:    rename,
+		Sprints:   sprints,
+		Subscribe: SubscribeMongo(database, "sprints"),
+		Upsert: func(projects []models.Project) (time.Duration, int64, error) {
+			return UpsertMongoWithReferencing(database, projects)
+		},
+		Evolution: EvolveMongo(database, "projects"),
+		Storage:   StorageMongo(database, "users", "projects", "sprints"),
+		Evict: func() error {
+			return EvictMongo(database)
+		},
+	}
+}
+
+func SqliteVariant(conn *sql.DB) Variant {
+	sprints := SprintFuncs{
+		Add: func(identifier string, sprint models.Sprint) (time.Duration, error) {
+			return InsertSqliteSprint(conn, identifier, sprint)
+		},
+		Update: func(identifier string, name string) (time.Duration, error) {
+			return UpdateSqliteSprint(conn, identifier, name)
+		},
+		Remove: func(identifier string, name string) (time.Duration, error) {
+			return DeleteSqliteSprint(conn, identifier, name)
+		},
+		Read: func(identifier string) (Measurement, error) {
+			return ReadSqliteProject(conn, identifier)
+		},
+	}
+
+	filters := map[string]FilterFunc{
+		"Find with filter": func(threshold int) (Measurement, error) {
+			return FindSqliteWithFilter(conn, threshold)
+		},
+		"Find with filter and projection": func(threshold int) (Measurement, error) {
+			return FindSqliteWithFilterAndProjection(conn, threshold)
+		},
+		"Find with filter and projection and sort": func(threshold int) (Measurement, error) {
+			return FindSqliteWithFilterAndProjectionAndSort(conn, threshold)
+		},
+	}
+
+	rename := func(username string, newUsername string) (time.Duration, int64, error) {
+		return RenameSqliteUser(conn, username, newUsername)
+	}
+
+	return Variant{
+		Name: "SQLite",
+		Reset: func() error {
+			return ResetSqlite(conn)
+		},
+		Count: func() (int64, error) {
+			return CountSqlite(conn)
+		},
+		State: func() (State, error) {
+			return StateSqlite(conn)
+		},
+		Operations: withPages(withSprints(map[string]OperationFunc{
+			"Insert": func(projects []models.Project) (Measurement, error) {
+				return timed(InsertSqlite(conn, projects))
+			},
+			"Find": func(projects []models.Project) (Measurement, error) {
+				return FindSqlite(conn)
+			},
+			"Find with filter":                         withThreshold(filters["Find with filter"], defaultThreshold),
+			"Find with filter and projection":          withThreshold(filters["Find with filter and projection"], defaultThreshold),
+			"Find with filter and projection and sort": withThreshold(filters["Find with filter and projection and sort"], defaultThreshold),
+			"Find with aggregation": func(projects []models.Project) (Measurement, error) {
+				return FindSqliteWithAggregation(conn)
+			},
+			"Update": func(projects []models.Project) (Measurement, error) {
+				return timed(UpdateSqlite(conn))
+			},
+			"Rename owner": withRename(rename),
+			"Delete project": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteSqliteProjects(conn, false, `identifier = ?1`, projects[0].Identifier))
+			},
+			"Delete project with cascade": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteSqliteProjects(conn, true, `identifier = ?1`, projects[1].Identifier))
+			},
+			"Delete by filter": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteSqliteProjects(conn, false, `sprint_duration BETWEEN ?1 AND ?2`, deleteDurations[0], deleteDurations[1]))
+			},
+			"Delete user": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteSqliteUser(conn, projects[2].Owner.Username))
+			},
+			"Delete": func(projects []models.Project) (Measurement, error) {
+				return timed(DeleteSqlite(conn))
+			},
+		}, sprints), func() (int64, error) {
+			return CountSqliteSprints(conn)
+		}, func(page int64, keyset bool) (Measurement, error) {
+			return PageSqlite(conn, page, keyset)
+		}),
+		Filters:  filters,
+		Searches: SqliteSearches(conn),
+		Rename:   rename,
+		Sprints:  sprints,
+		Upsert: func(projects []models.Project) (time.Duration, int64, error) {
+			return UpsertSqlite(conn, projects)
+		},
+		Evolution: EvolveSqlite(conn),
+		Storage:   StorageSqlite(conn),
+		InProcess: true,
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// State summarizes the data of a variant after an operation. Since every
+// variant runs the same operations on the same projects, all of them have to
+// end up in the same state. The sums serve as checksums of the fields the
+// operations change, and OwnerNames sums the length of the owner's username
+// over all projects, which shows whether a rename reached every project.
+// Orphans counts records that point at a record that no longer exists, such
+// as the sprints of a deleted project.
+type State struct {
+	Users           int64 `bson:"users"`
+	Projects        int64 `bson:"projects"`
+	Sprints         int64 `bson:"sprints"`
+	SprintDurations int64 `bson:"sprint_durations"`
+	StartDates      int64 `bson:"start_dates"`
+	EndDates        int64 `bson:"end_dates"`
+	OwnerNames      int64 `bson:"owner_names"`
+	Orphans         int64 `bson:"orphans"`
+}
+
+func (state State) String() string {
+	return fmt.Sprintf("%d users, %d projects, %d sprints, durations %d, start dates %d, end dates %d, owner names %d, %d orphans",
+		state.Users, state.Projects, state.Sprints, state.SprintDurations, state.StartDates, state.EndDates, state.OwnerNames, state.Orphans)
+}
+
+// Mismatch records a variant whose state differs from the first variant's
+// after an operation.
+type Mismatch struct {
+	Size      int
+	Operation string
+	Variant   string
+	Expected  State
+	Actual    State
+}
+
+// States collects the state of every variant, keyed by variant name.
+func States(variants []Variant) (states map[string]State, err error) {
+	states = make(map[string]State)
+	for _, variant := range variants {
+		states[variant.Name], err = variant.State()
+		if err != nil {
+			return nil, fmt.Errorf("collecting state of %s: %w", variant.Name, err)
+		}
+	}
+	return states, nil
+}
+
+// VerifyStates compares the state of every variant to the first one. No
+// variant may hold orphans, the first one included.
+func VerifyStates(size int, operation string, variants []Variant, states map[string]State) (mismatches []Mismatch) {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	expected := states[variants[0].Name]
+	expected.Orphans = 0
+	for _, variant := range variants {
+		actual := states[variant.Name]
+		if actual != expected {
+			mismatches = append(mismatches, Mismatch{size, operation, variant.Name, expected, actual})
+		}
+	}
+	return mismatches
+}
+
+// CardinalityMismatch records a read that returned a different number of
+// records than the same read on the first variant that ran it.
+type CardinalityMismatch struct {
+	Size      int
+	Operation string
+	Reference string
+	Variant   string
+	Expected  int64
+	Actual    int64
+}
+
+// VerifyCardinality compares the number of records every variant returned
+// for a read to the first variant that ran it.
+func VerifyCardinality(size int, operation string, variants []Variant, results map[string]Measurement) (mismatches []CardinalityMismatch) {
+	var reference string
+	for _, variant := range variants {
+		result, ok := results[variant.Name]
+		if !ok {
+			continue
+		}
+
+		if reference == "" {
+			reference = variant.Name
+			continue
+		}
+
+		expected := results[reference].Rows
+		if result.Rows != expected {
+			mismatches = append(mismatches, CardinalityMismatch{size, operation, reference, variant.Name, expected, result.Rows})
+		}
+	}
+	return mismatches
+}
+
+const sqlState = `SELECT
+	(SELECT COUNT(*) FROM users),
+	(SELECT COUNT(*) FROM projects),
+	(SELECT COUNT(*) FROM sprints),
+	(SELECT COALESCE(SUM(sprint_duration), 0) FROM projects),
+	(SELECT COALESCE(SUM(start_date), 0) FROM sprints),
+	(SELECT COALESCE(SUM(end_date), 0) FROM sprints),
+	(SELECT COALESCE(SUM(LENGTH(users.username)), 0) FROM projects JOIN users ON users.id = projects.owner_id),
+	(SELECT COUNT(*) FROM sprints WHERE NOT EXISTS (SELECT 1 FROM projects WHERE projects.id = sprints.project_id))
+		+ (SELECT COUNT(*) FROM projects WHERE NOT EXISTS (SELECT 1 FROM users WHERE users.id = projects.owner_id));`
+
+func StatePostgres(conn *pgxpool.Pool) (state State, err error) {
+	err = conn.QueryRow(context.Background(), sqlState).Scan(&state.Users, &state.Projects, &state.Sprints, &state.SprintDurations, &state.StartDates, &state.EndDates, &state.OwnerNames, &state.Orphans)
+	return state, err
+}
+
+func StateSqlite(conn *sql.DB) (state State, err error) {
+	err = conn.QueryRowContext(context.Background(), sqlState).Scan(&state.Users, &state.Projects, &state.Sprints, &state.SprintDurations, &state.StartDates, &state.EndDates, &state.OwnerNames, &state.Orphans)
+	return state, err
+}
+
+// StateMongo summarizes a collection of embedded projects, in which every
+// project carries a copy of its owner. Owners are told apart by username.
+func StateMongo(database *mongo.Database, collection string) (state State, err error) {
+	ctx := context.Background()
+	cursor, err := database.Collection(collection).Aggregate(ctx, []bson.M{
+		{
+			"$group": bson.M{
+				"_id":              nil,
+				"owners":           bson.M{"$addToSet": "$owner.username"},
+				"projects":         bson.M{"$sum": 1},
+				"sprints":          bson.M{"$sum": bson.M{"$size": bson.M{"$ifNull": bson.A{"$sprints", bson.A{}}}}},
+				"sprint_durations": bson.M{"$sum": "$sprint_duration"},
+				"start_dates":      bson.M{"$sum": bson.M{"$sum": "$sprints.start_date"}},
+				"end_dates":        bson.M{"$sum": bson.M{"$sum": "$sprints.end_date"}},
+				"owner_names":      bson.M{"$sum": bson.M{"$strLenCP": bson.M{"$ifNull": bson.A{"$owner.username", ""}}}},
+			},
+		},
+		{"$set": bson.M{"users": bson.M{"$size": "$owners"}}},
+	})
+	if err != nil {
+		return state, err
+	}
+	defer cursor.Close(ctx)
+
+	// An empty collection yields no group at all.
+	if cursor.Next(ctx) {
+		err = cursor.Decode(&state)
+		if err != nil {
+			return state, err
+		}
+	}
+	return state, cursor.Err()
+}
+
+func StateMongoWithReferencing(database *mongo.Database) (state State, err error) {
+	ctx := context.Background()
+	state.Users, err = database.Collection("users").CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return state, err
+	}
+
+	sums := []struct {
+		collection string
+		group      bson.M
+	}{
+		{"projects", bson.M{"_id": nil, "projects": bson.M{"$sum": 1}, "sprint_durations": bson.M{"$sum": "$sprint_duration"}}},
+		{"sprints", bson.M{"_id": nil, "sprints": bson.M{"$sum": 1}, "start_dates": bson.M{"$sum": "$start_date"}, "end_dates": bson.M{"$sum": "$end_date"}}},
+	}
+	for _, sum := range sums {
+		cursor, err := database.Collection(sum.collection).Aggregate(ctx, []bson.M{{"$group": sum.group}})
+		if err != nil {
+			return state, err
+		}
+
+		var partial State
+		if cursor.Next(ctx) {
+			err = cursor.Decode(&partial)
+		}
+		cursor.Close(ctx)
+		if err != nil {
+			return state, err
+		}
+
+		state.Projects += partial.Projects
+		state.Sprints += partial.Sprints
+		state.SprintDurations += partial.SprintDurations
+		state.StartDates += partial.StartDates
+		state.EndDates += partial.EndDates
+	}
+
+	state.OwnerNames, err = sumMongoOwnerNames(ctx, database)
+	if err != nil {
+		return state, err
+	}
+
+	state.Orphans, err = countMongoOrphans(ctx, database)
+	return state, err
+}
+
+// sumMongoOwnerNames sums the length of the owner's username over the
+// referencing projects, looking every owner up once.
+func sumMongoOwnerNames(ctx context.Context, database *mongo.Database) (sum int64, err error) {
+	cursor, err := database.Collection("projects").Aggregate(ctx, []bson.M{
+		{"$group": bson.M{"_id": "$owner", "projects": bson.M{"$sum": 1}}},
+		{"$lookup": bson.M{"from": "users", "localField": "_id", "foreignField": "_id", "as": "owner"}},
+		{"$unwind": "$owner"},
+		{"$group": bson.M{"_id": nil, "owner_names": bson.M{"$sum": bson.M{"$multiply": bson.A{"$projects", bson.M{"$strLenCP": "$owner.username"}}}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var state State
+	if cursor.Next(ctx) {
+		err = cursor.Decode(&state)
+	}
+	return state.OwnerNames, err
+}
+
+// countMongoOrphans counts the referencing sprints whose project and the
+// projects whose owner are gone.
+func countMongoOrphans(ctx context.Context, database *mongo.Database) (orphans int64, err error) {
+	references := []struct {
+		collection string
+		field      string
+		from       string
+	}{
+		{"sprints", "project", "projects"},
+		{"projects", "owner", "users"},
+	}
+	for _, reference := range references {
+		cursor, err := database.Collection(reference.collection).Aggregate(ctx, []bson.M{
+			{"$lookup": bson.M{"from": reference.from, "localField": reference.field, "foreignField": "_id", "as": "referenced"}},
+			{"$match": bson.M{"referenced": bson.M{"$size": 0}}},
+			{"$count": "orphans"},
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		var partial State
+		if cursor.Next(ctx) {
+			err = cursor.Decode(&partial)
+		}
+		cursor.Close(ctx)
+		if err != nil {
+			return 0, err
+		}
+		orphans += partial.Orphans
+	}
+	return orphans, nil
+}